@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// overwritePolicy controls what happens when an export file already exists.
+type overwritePolicy int
+
+const (
+	overwriteSuffix    overwritePolicy = iota // Add -1, -2, ... to the file name (default)
+	overwriteReplace                          // Replace the existing file
+	overwriteNoClobber                        // Fail, leaving the existing file untouched
+)
+
+// maxSuffix bounds the search for a free file name with overwriteSuffix.
+const maxSuffix = 1000
+
+var errFileExists = errors.New("file already exists")
+
+// atomicFile is a temporary file in the target's directory that is only moved
+// into place once it has been completely written and flushed to disk, so a crash
+// or full disk never leaves a truncated export behind.
+type atomicFile struct {
+	*os.File
+	target string
+}
+
+// createAtomic creates a temporary file that will become target on Commit.
+func createAtomic(target string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	return &atomicFile{File: f, target: target}, nil
+}
+
+// Commit flushes the file to disk and moves it into place according to policy.
+// It returns the final path, which differs from the target with overwriteSuffix.
+// The temporary file is always removed, even on failure.
+func (f *atomicFile) Commit(policy overwritePolicy) (string, error) {
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath) //nolint:errcheck // Already renamed on success, best effort cleanup otherwise
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close() //nolint:errcheck // Sync error takes precedence
+		return "", fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+
+	switch policy {
+	case overwriteReplace:
+		if err := os.Rename(tmpPath, f.target); err != nil {
+			return "", fmt.Errorf("%w: %w", errWriteExport, err)
+		}
+		return f.target, nil
+	case overwriteNoClobber:
+		if err := placeExclusive(tmpPath, f.target); err != nil {
+			return "", fmt.Errorf("%w: %w", errWriteExport, err)
+		}
+		return f.target, nil
+	case overwriteSuffix:
+		for n := range maxSuffix {
+			candidate := suffixedPath(f.target, n)
+			err := placeExclusive(tmpPath, candidate)
+			if err == nil {
+				return candidate, nil
+			}
+			if !errors.Is(err, errFileExists) {
+				return "", fmt.Errorf("%w: %w", errWriteExport, err)
+			}
+		}
+		return "", fmt.Errorf("%w: no free file name for %s", errWriteExport, f.target)
+	}
+	return "", fmt.Errorf("%w: unknown overwrite policy %d", errWriteExport, policy)
+}
+
+// Abort discards the temporary file.
+func (f *atomicFile) Abort() {
+	_ = f.Close()           //nolint:errcheck // Discarding the file anyway
+	_ = os.Remove(f.Name()) //nolint:errcheck // Best effort cleanup
+}
+
+// writeFileAtomic writes data to path through an atomicFile and returns the final path.
+func writeFileAtomic(path string, data []byte, policy overwritePolicy) (string, error) {
+	f, err := createAtomic(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return "", fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	return f.Commit(policy)
+}
+
+// placeExclusive moves src to dst, failing with errFileExists if dst already exists.
+// A hard link makes the check and the move a single atomic step; filesystems
+// without hard links fall back to checking before renaming.
+func placeExclusive(src, dst string) error {
+	err := os.Link(src, dst)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrExist):
+		return fmt.Errorf("%w: %s", errFileExists, dst)
+	}
+
+	if _, statErr := os.Lstat(dst); statErr == nil {
+		return fmt.Errorf("%w: %s", errFileExists, dst)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+	return nil
+}
+
+// suffixedPath returns path with -n inserted before its extension(s),
+// e.g. export.json.gz becomes export-2.json.gz. n == 0 returns path unchanged.
+func suffixedPath(path string, n int) string {
+	if n == 0 {
+		return path
+	}
+	dir, base := filepath.Split(path)
+	name, ext := base, ""
+	if i := strings.IndexByte(base, '.'); i > 0 {
+		name, ext = base[:i], base[i:]
+	}
+	return dir + name + "-" + strconv.Itoa(n) + ext
+}
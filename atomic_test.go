@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSuffixedPath(t *testing.T) {
+	tests := []struct {
+		path string
+		n    int
+		want string
+	}{
+		{path: "export.json", n: 0, want: "export.json"},
+		{path: "export.json", n: 1, want: "export-1.json"},
+		{path: "export.json.gz.age", n: 2, want: "export-2.json.gz.age"},
+		{path: "dir.d/export", n: 3, want: "dir.d/export-3"},
+		{path: ".hidden", n: 1, want: ".hidden-1"},
+	}
+	for _, tt := range tests {
+		if got := suffixedPath(tt.path, tt.n); got != tt.want {
+			t.Errorf("suffixedPath(%q, %d) = %q, want %q", tt.path, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestAtomicFileCommit(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   overwritePolicy
+		existing []string // Files present before the commit
+		wantFile string   // Final file name
+		wantErr  error
+	}{
+		{name: "suffix without conflict", policy: overwriteSuffix, wantFile: "export.json"},
+		{name: "suffix", policy: overwriteSuffix, existing: []string{"export.json"}, wantFile: "export-1.json"},
+		{name: "suffix skips taken names", policy: overwriteSuffix, existing: []string{"export.json", "export-1.json"}, wantFile: "export-2.json"},
+		{name: "replace without conflict", policy: overwriteReplace, wantFile: "export.json"},
+		{name: "replace", policy: overwriteReplace, existing: []string{"export.json"}, wantFile: "export.json"},
+		{name: "no-clobber without conflict", policy: overwriteNoClobber, wantFile: "export.json"},
+		{name: "no-clobber", policy: overwriteNoClobber, existing: []string{"export.json"}, wantErr: errFileExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.existing {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("old"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := writeFileAtomic(filepath.Join(dir, "export.json"), []byte("new"), tt.policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("writeFileAtomic() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				if want := filepath.Join(dir, tt.wantFile); got != want {
+					t.Errorf("writeFileAtomic() = %q, want %q", got, want)
+				}
+				if data, err := os.ReadFile(got); err != nil || string(data) != "new" {
+					t.Errorf("%s contains %q, %v, want %q", got, data, err, "new")
+				}
+			}
+
+			// Existing files are only touched by overwriteReplace
+			for _, name := range tt.existing {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				replaced := tt.policy == overwriteReplace && name == tt.wantFile
+				if string(data) != "old" && !replaced {
+					t.Errorf("%s was overwritten", name)
+				}
+			}
+
+			// No temporary file is left behind
+			want := make(map[string]bool)
+			for _, name := range tt.existing {
+				want[name] = true
+			}
+			if tt.wantErr == nil {
+				want[tt.wantFile] = true
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if !want[entry.Name()] {
+					t.Errorf("unexpected file %s left behind", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestAtomicFileAbort(t *testing.T) {
+	dir := t.TempDir()
+	f, err := createAtomic(filepath.Join(dir, "export.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("partial"); err != nil {
+		t.Fatal(err)
+	}
+	f.Abort()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Abort left %d files behind", len(entries))
+	}
+}
+
+func TestPlaceExclusive(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	for _, path := range []string{src, dst} {
+		if err := os.WriteFile(path, []byte(filepath.Base(path)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := placeExclusive(src, dst); !errors.Is(err, errFileExists) {
+		t.Fatalf("placeExclusive() onto an existing file error = %v, want errFileExists", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "dst" {
+		t.Errorf("existing file was overwritten with %q", data)
+	}
+
+	free := filepath.Join(dir, "free")
+	if err := placeExclusive(src, free); err != nil {
+		t.Fatalf("placeExclusive() error = %v", err)
+	}
+	if data, _ := os.ReadFile(free); string(data) != "src" {
+		t.Errorf("placed file contains %q, want %q", data, "src")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// defaultConcurrency is the number of budgets downloaded at once when exporting several budgets.
+const defaultConcurrency = 3
+
+// budgetExport is the outcome of exporting one budget as part of a batch.
+type budgetExport struct {
+	err    error
+	budget budget
+	result exportResult
+}
+
+// exportBudgets exports each budget with at most concurrency downloads in flight.
+// A failed budget is recorded in its result rather than aborting the batch.
+// Results are returned in the same order as budgets. The downloads are tracked in
+// progress, if not nil.
+func exportBudgets(ctx context.Context, c *client, budgets []budget, concurrency int, opts exportOptions,
+	progress *transferProgress,
+) []budgetExport {
+	concurrency = max(concurrency, 1)
+
+	exports := make([]budgetExport, len(budgets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, b := range budgets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result, err := c.downloadBudget(ctx, b.ID, b.Name, opts, progress)
+			exports[i] = budgetExport{budget: b, result: result, err: err}
+		}()
+	}
+	wg.Wait()
+
+	return exports
+}
+
+// runSummary is the combined report written after a batch export.
+type runSummary struct {
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at"`
+	Budgets    []runSummaryBudget `json:"budgets"`
+	Succeeded  int                `json:"succeeded"`
+	Failed     int                `json:"failed"`
+}
+
+// runSummaryBudget is the per-budget entry of a runSummary.
+type runSummaryBudget struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	Path             string   `json:"path,omitempty"`
+	Paths            []string `json:"paths,omitempty"` // For formats written per account
+	Error            string   `json:"error,omitempty"`
+	FileSize         int64    `json:"file_size,omitempty"`
+	CompressedSize   int64    `json:"compressed_size,omitempty"`
+	Encryption       string   `json:"encryption,omitempty"`
+	ServerKnowledge  int64    `json:"server_knowledge,omitempty"`
+	DeltaSince       int64    `json:"delta_since,omitempty"`
+	TransactionCount int      `json:"transaction_count,omitempty"`
+}
+
+// writeRunSummary writes a JSON report of a batch export to the output directory
+// and returns its path.
+func writeRunSummary(exports []budgetExport, startedAt time.Time, opts exportOptions) (string, error) {
+	summary := runSummary{
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+		Budgets:    make([]runSummaryBudget, len(exports)),
+	}
+	for i, e := range exports {
+		entry := runSummaryBudget{
+			ID:   e.budget.ID,
+			Name: cmp.Or(e.result.summary.Name, e.budget.Name),
+		}
+		if e.err != nil {
+			entry.Error = e.err.Error()
+			summary.Failed++
+		} else {
+			if len(e.result.paths) == 1 {
+				entry.Path = e.result.paths[0]
+			} else {
+				entry.Paths = e.result.paths
+			}
+			entry.FileSize = e.result.summary.FileSize
+			entry.CompressedSize = e.result.summary.CompressedSize
+			entry.Encryption = e.result.summary.Encryption
+			entry.ServerKnowledge = e.result.summary.ServerKnowledge
+			entry.DeltaSince = e.result.summary.DeltaSince
+			entry.TransactionCount = e.result.summary.TransactionCount
+			summary.Succeeded++
+		}
+		summary.Budgets[i] = entry
+	}
+
+	data, err := json.Marshal(summary, jsontext.WithIndent("  "))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode run summary: %w", err)
+	}
+
+	dir, err := opts.ensureDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("ynab-export-summary-%s.json", startedAt.Format("20060102-150405")))
+	return writeFileAtomic(path, data, opts.overwrite)
+}
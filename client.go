@@ -0,0 +1,295 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// defaultAPIBase is the base URL for the YNAB API.
+const defaultAPIBase = "https://api.ynab.com/v1"
+
+// Retry settings for requests that fail with a server or network error.
+const (
+	maxRetries     = 3
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 8 * time.Second
+)
+
+// defaultTimeout is how long a request may go without receiving anything from the API.
+const defaultTimeout = 30 * time.Second
+
+// errTimeout marks requests that the API stopped responding to.
+var errTimeout = errors.New("request timed out")
+
+// clientOptions configures how the client connects to the YNAB API.
+type clientOptions struct {
+	transport     http.RoundTripper // Built by resolve from the TLS and proxy settings
+	baseURL       string            // Defaults to defaultAPIBase; overridden in demo mode
+	caCert        string            // PEM bundle trusted in addition to the system roots
+	clientCert    string            // PEM certificate for mutual TLS
+	clientKey     string            // PEM key for clientCert, if not in the same file
+	proxy         string            // Proxy URL; defaults to the HTTPS_PROXY environment variable
+	timeout       time.Duration     // Defaults to defaultTimeout
+	waitRateLimit bool              // Wait for the rate limit to allow more requests instead of failing
+	logger        *slog.Logger      // Logs every request and response when set, for --debug
+	logFile       string            // Where logger writes, to point the user to it
+}
+
+// resolve fills in settings not given on the command line from the environment,
+// then the config file, then the defaults, and validates the result.
+func (o *clientOptions) resolve(cfg config) error {
+	if value := cmp.Or(os.Getenv("YNAB_EXPORT_TIMEOUT"), cfg.Timeout); o.timeout == 0 && value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", value, err)
+		}
+		o.timeout = timeout
+	}
+	o.timeout = cmp.Or(o.timeout, defaultTimeout)
+	if o.timeout < 0 {
+		return fmt.Errorf("invalid timeout %s: must be positive", o.timeout)
+	}
+
+	o.caCert = cmp.Or(o.caCert, os.Getenv("YNAB_EXPORT_CA_CERT"), cfg.CACert)
+	o.clientCert = cmp.Or(o.clientCert, os.Getenv("YNAB_EXPORT_CLIENT_CERT"), cfg.ClientCert)
+	o.clientKey = cmp.Or(o.clientKey, os.Getenv("YNAB_EXPORT_CLIENT_KEY"), cfg.ClientKey)
+	o.proxy = cmp.Or(o.proxy, os.Getenv("YNAB_EXPORT_PROXY"), cfg.Proxy)
+	for _, path := range []*string{&o.caCert, &o.clientCert, &o.clientKey} {
+		expanded, err := expandHome(*path)
+		if err != nil {
+			return err
+		}
+		*path = expanded
+	}
+
+	transport, err := newTransport(*o)
+	if err != nil {
+		return err
+	}
+	o.transport = transport
+	if o.logger != nil {
+		o.transport = &loggingTransport{next: transport, logger: o.logger}
+	}
+	return nil
+}
+
+// client is a YNAB API client. It authenticates every request and retries
+// requests that fail with a 5xx status or a network error, with exponential
+// backoff and jitter. It also keeps track of the token's rate limit.
+type client struct {
+	httpClient    *http.Client
+	rateLimit     *rateTracker
+	baseURL       string
+	token         string
+	userAgent     string
+	timeout       time.Duration
+	waitRateLimit bool
+}
+
+// newClient creates a client that authenticates with token.
+func newClient(token string, opts clientOptions) *client {
+	baseURL := opts.baseURL
+	if baseURL == "" {
+		baseURL = defaultAPIBase
+	}
+	return &client{
+		httpClient:    &http.Client{Transport: opts.transport},
+		rateLimit:     loadRateTracker(token),
+		baseURL:       baseURL,
+		token:         token,
+		userAgent:     "ynab-export/" + version,
+		timeout:       cmp.Or(opts.timeout, defaultTimeout),
+		waitRateLimit: opts.waitRateLimit,
+	}
+}
+
+// get requests path, relative to the base URL, and returns the response if it has
+// status 200. Any other status is returned as an *apiError, except that hitting
+// the rate limit returns errRateLimited. The caller must close the response body.
+func (c *client) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	rateLimitWaits := 0
+	for attempt := 0; ; attempt++ {
+		if err := c.awaitRateLimit(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.attempt(ctx, target)
+		if resp != nil {
+			c.rateLimit.record(resp, time.Now())
+		}
+
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			wait := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			_, _ = io.Copy(io.Discard, resp.Body) //nolint:errcheck // Discarded response
+			_ = resp.Body.Close()                 //nolint:errcheck // Discarded response
+			if !c.waitRateLimit || rateLimitWaits == maxRetries {
+				return nil, rateLimitedError(wait)
+			}
+			rateLimitWaits++
+			attempt-- // Waiting for the rate limit is not a failed attempt
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, fmt.Errorf("request canceled while waiting for the rate limit: %w", err)
+			}
+			continue
+		}
+
+		// Certificate problems do not go away by retrying
+		err = diagnoseTLS(err)
+		retry := attempt < maxRetries && ctx.Err() == nil && !errors.Is(err, errTLSVerification) &&
+			(err != nil || resp.StatusCode >= http.StatusInternalServerError)
+		if !retry {
+			switch {
+			case err != nil && attempt > 0:
+				return nil, fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+			case err != nil:
+				return nil, err //nolint:wrapcheck // Callers add what the request was for
+			case resp.StatusCode != http.StatusOK:
+				defer resp.Body.Close() //nolint:errcheck // Error response already read
+				return nil, newAPIError(resp)
+			}
+			return resp, nil
+		}
+
+		if resp != nil {
+			// Drain the body so the connection can be reused for the retry
+			_, _ = io.Copy(io.Discard, resp.Body) //nolint:errcheck // Discarded response
+			_ = resp.Body.Close()                 //nolint:errcheck // Discarded response
+		}
+		if err := sleepContext(ctx, retryDelay(attempt)); err != nil {
+			return nil, fmt.Errorf("request canceled while waiting to retry: %w", err)
+		}
+	}
+}
+
+// attempt makes a single request for target. It is canceled when ctx is, or when
+// nothing is received from the API for the client's timeout, whether while
+// waiting for the response or while its body is read. Large downloads can
+// therefore take as long as they need, as long as data keeps arriving.
+func (c *client) attempt(ctx context.Context, target string) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	watchdog := time.AfterFunc(c.timeout, func() { cancel(errTimeout) })
+	stop := func() {
+		watchdog.Stop()
+		cancel(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, http.NoBody)
+	if err != nil {
+		stop()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		stop()
+		return nil, c.timeoutError(ctx, err)
+	}
+	resp.Body = &watchdogBody{ReadCloser: resp.Body, client: c, ctx: ctx, watchdog: watchdog, stop: stop}
+	return resp, nil
+}
+
+// timeoutError replaces err with a clearer error if the request in ctx timed out.
+func (c *client) timeoutError(ctx context.Context, err error) error {
+	if errors.Is(context.Cause(ctx), errTimeout) {
+		return fmt.Errorf("%w: nothing received from YNAB for %s", errTimeout, c.timeout)
+	}
+	return err
+}
+
+// watchdogBody is a response body that restarts the request's timeout whenever
+// data is received, and stops it when closed.
+type watchdogBody struct {
+	io.ReadCloser
+	client   *client
+	ctx      context.Context //nolint:containedctx // The context of the request the body belongs to
+	watchdog *time.Timer
+	stop     func()
+}
+
+func (b *watchdogBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.watchdog.Reset(b.client.timeout)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, b.client.timeoutError(b.ctx, err)
+	}
+	return n, err //nolint:wrapcheck // io.EOF must be returned as is
+}
+
+func (b *watchdogBody) Close() error {
+	b.stop()
+	return b.ReadCloser.Close() //nolint:wrapcheck // Transparent pass-through body
+}
+
+// awaitRateLimit checks that the rate limit allows another request. If it does
+// not, it waits for one to become available or fails, depending on the client's settings.
+func (c *client) awaitRateLimit(ctx context.Context) error {
+	wait := c.rateLimit.waitFor(1, time.Now())
+	if wait <= 0 {
+		return nil
+	}
+	if !c.waitRateLimit {
+		return rateLimitedError(wait)
+	}
+	if err := sleepContext(ctx, wait); err != nil {
+		return fmt.Errorf("request canceled while waiting for the rate limit: %w", err)
+	}
+	return nil
+}
+
+// getJSON requests path and decodes the JSON response into v.
+func (c *client) getJSON(ctx context.Context, path string, query url.Values, v any) error {
+	resp, err := c.get(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck // Body fully read below
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// retryDelay returns how long to wait before retry number attempt+1: an
+// exponentially growing delay, of which a random half is added as jitter so
+// concurrent downloads do not retry in lockstep.
+func retryDelay(attempt int) time.Duration {
+	delay := min(initialBackoff<<attempt, maxBackoff)
+	return delay/2 + rand.N(delay/2) //nolint:gosec // Jitter does not need a secure random source
+}
+
+// sleepContext waits for d, returning early with an error if ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err() //nolint:wrapcheck // Context errors are returned as is
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// isolateCache points the user cache and config directories at a temporary
+// directory, so tests do not touch the real token cache or rate limit file.
+func isolateCache(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("LocalAppData", dir)
+	t.Setenv("AppData", dir)
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempt := range 8 {
+		full := min(initialBackoff<<attempt, maxBackoff)
+		for range 50 {
+			if d := retryDelay(attempt); d < full/2 || d >= full {
+				t.Fatalf("retryDelay(%d) = %s, want within [%s, %s)", attempt, d, full/2, full)
+			}
+		}
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   apiError
+		msg    string
+	}{
+		{
+			name:   "YNAB error",
+			status: http.StatusNotFound,
+			body:   `{"error":{"id":"404.2","name":"resource_not_found","detail":"Resource not found"}}`,
+			want:   apiError{StatusCode: 404, ID: "404.2", Name: "resource_not_found", Detail: "Resource not found"},
+			msg:    "API error: 404 Not Found - resource_not_found: Resource not found",
+		},
+		{
+			name:   "plain body",
+			status: http.StatusBadGateway,
+			body:   "upstream unavailable\n",
+			want:   apiError{StatusCode: 502},
+			msg:    "API error: 502 Bad Gateway - upstream unavailable",
+		},
+		{
+			name:   "empty body",
+			status: http.StatusUnauthorized,
+			want:   apiError{StatusCode: 401},
+			msg:    "API error: 401 Unauthorized",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rec.WriteHeader(tt.status)
+			_, _ = io.WriteString(rec, tt.body)
+
+			var apiErr *apiError
+			if err := newAPIError(rec.Result()); !errors.As(err, &apiErr) {
+				t.Fatalf("newAPIError() = %v, want an *apiError", err)
+			}
+			if apiErr.StatusCode != tt.want.StatusCode || apiErr.ID != tt.want.ID ||
+				apiErr.Name != tt.want.Name || apiErr.Detail != tt.want.Detail {
+				t.Errorf("newAPIError() = %+v, want %+v", *apiErr, tt.want)
+			}
+			if apiErr.Error() != tt.msg {
+				t.Errorf("Error() = %q, want %q", apiErr.Error(), tt.msg)
+			}
+		})
+	}
+}
+
+func TestClientGet(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int // Status of each response, the last one repeated
+		wantRequests int32
+		wantStatus   int // Status of the returned *apiError, or 0 for success
+	}{
+		{name: "success", statuses: []int{200}, wantRequests: 1},
+		{name: "retries server errors", statuses: []int{500, 503, 200}, wantRequests: 3},
+		{name: "does not retry client errors", statuses: []int{404}, wantRequests: 1, wantStatus: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateCache(t)
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(requests.Add(1))
+				if r.Header.Get("Authorization") != "Bearer token" {
+					t.Errorf("request has Authorization %q", r.Header.Get("Authorization"))
+				}
+				w.WriteHeader(tt.statuses[min(n, len(tt.statuses))-1])
+				_, _ = io.WriteString(w, `{"data":{}}`)
+			}))
+			defer server.Close()
+
+			c := newClient("token", clientOptions{baseURL: server.URL, timeout: 5 * time.Second})
+			resp, err := c.get(context.Background(), "/budgets", nil)
+			if resp != nil {
+				resp.Body.Close()
+			}
+
+			var apiErr *apiError
+			switch {
+			case tt.wantStatus == 0 && err != nil:
+				t.Fatalf("get() error = %v", err)
+			case tt.wantStatus != 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus):
+				t.Fatalf("get() error = %v, want an API error with status %d", err, tt.wantStatus)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
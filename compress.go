@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compression is the algorithm used to compress export files.
+type compression string
+
+const (
+	compressionNone compression = ""
+	compressionGzip compression = "gzip"
+	compressionZstd compression = "zstd"
+)
+
+// parseCompression validates a --compress value.
+func parseCompression(s string) (compression, error) {
+	switch c := compression(s); c {
+	case compressionNone, compressionGzip, compressionZstd:
+		return c, nil
+	default:
+		return compressionNone, fmt.Errorf("unsupported compression %q (use gzip or zstd)", s)
+	}
+}
+
+// ext returns the file extension added for the compression, including the dot.
+func (c compression) ext() string {
+	switch c {
+	case compressionGzip:
+		return ".gz"
+	case compressionZstd:
+		return ".zst"
+	case compressionNone:
+	}
+	return ""
+}
+
+// newWriter wraps w so that data written is compressed. Closing the returned
+// writer flushes the compressed stream but does not close w.
+func (c compression) newWriter(w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case compressionGzip:
+		return gzip.NewWriter(w), nil
+	case compressionZstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		return zw, nil
+	case compressionNone:
+	}
+	return nopWriteCloser{w}, nil
+}
+
+// Magic numbers at the start of compressed and encrypted files.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	ageMagic  = []byte("age-encryption.org/")
+)
+
+// newDecompressor returns a reader that decompresses r if it is gzip or zstd
+// compressed, detected from its first bytes, and reads it unchanged otherwise.
+func newDecompressor(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(ageMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip data: %w", err)
+		}
+		return zr, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd data: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	case bytes.HasPrefix(magic, ageMagic):
+		return nil, errors.New("file is encrypted, decrypt it first with: ynab-export decrypt")
+	}
+	return io.NopCloser(br), nil
+}
+
+// nopWriteCloser adds a no-op Close to an io.Writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err //nolint:wrapcheck // Transparent pass-through writer
+}
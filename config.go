@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const configFileName = "config.json"
+
+// config holds the settings read from the config file.
+// Every setting is optional; command-line flags and environment variables take priority.
+type config struct {
+	OutputDir        string        `json:"output_dir"`
+	FilenameTemplate string        `json:"filename_template"`
+	Format           string        `json:"format"`
+	Timeout          string        `json:"timeout"` // A duration such as "45s" or "2m"
+	CACert           string        `json:"ca_cert"`
+	ClientCert       string        `json:"client_cert"`
+	ClientKey        string        `json:"client_key"`
+	Proxy            string        `json:"proxy"`
+	Journal          journalConfig `json:"journal"`
+}
+
+// getConfigPath returns the path to the config file.
+// YNAB_EXPORT_CONFIG overrides the default location in the user config directory.
+func getConfigPath() (string, error) {
+	if path := os.Getenv("YNAB_EXPORT_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %w", err)
+	}
+
+	return filepath.Join(configDir, appDirName, configFileName), nil
+}
+
+// loadConfig reads the config file.
+// Returns an empty config and nil if the file does not exist.
+func loadConfig() (config, error) {
+	var cfg config
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return cfg, err
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil // No config file, use defaults
+		}
+		return cfg, fmt.Errorf("failed to read config from %s: %w", configPath, err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config file %s: %w", configPath, err)
+	}
+
+	return cfg, nil
+}
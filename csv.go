@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// csvHeader lists the columns of a CSV transaction register.
+var csvHeader = []string{
+	"Date", "Account", "Payee", "Category Group", "Category", "Memo", "Outflow", "Inflow",
+	"Cleared", "Approved", "Flag", "Transfer Account", "Transaction ID", "Parent Transaction ID",
+}
+
+// writeCSV writes the budget's transactions to w as a CSV register, with amounts
+// formatted in the budget's currency.
+func writeCSV(w io.Writer, budget budgetDetail) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+
+	for _, e := range budgetRegister(budget) {
+		// Both columns are filled, as in YNAB's own CSV export, so they can be summed
+		outflow := budget.CurrencyFormat.format(max(-e.Amount, 0))
+		inflow := budget.CurrencyFormat.format(max(e.Amount, 0))
+		record := []string{
+			e.Date, e.Account, e.Payee, e.CategoryGroup, e.Category, e.Memo, outflow, inflow,
+			e.Cleared, strconv.FormatBool(e.Approved), e.Flag, e.TransferAccount, e.ID, e.ParentID,
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("%w: %w", errWriteExport, err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	var b strings.Builder
+	if err := writeCSV(&b, testBudget()); err != nil {
+		t.Fatalf("writeCSV() error = %v", err)
+	}
+
+	want := `Date,Account,Payee,Category Group,Category,Memo,Outflow,Inflow,Cleared,Approved,Flag,Transfer Account,Transaction ID,Parent Transaction ID
+2025-01-03,Checking,Grocer,Food,Groceries,Weekly shop,$45.67,$0.00,reconciled,true,red,,t-shop,
+2025-01-05,Checking,Employer,Internal Master Category,Inflow: Ready to Assign,,$0.00,"$2,000.00",cleared,true,,,t-pay,
+2025-01-08,Visa,Grocer,Food,Groceries,,$12.34,$0.00,uncleared,false,,,t-card,
+2025-01-10,Checking,Grocer,Food,Groceries,Food,$80.00,$0.00,cleared,true,Review,,s-food,t-split
+2025-01-10,Checking,Grocer,Bills,Rent,Big shop,$40.00,$0.00,cleared,true,Review,,s-rent,t-split
+2025-01-12,Checking,Transfer : Savings,,,,$500.00,$0.00,uncleared,true,,Savings,t-to-sav,
+2025-01-12,Savings,Transfer : Checking,,,,$0.00,$500.00,cleared,true,,Checking,t-from-chk,
+`
+	if got := b.String(); got != want {
+		t.Errorf("writeCSV() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	debugLogFileName = "debug.log"
+
+	// maxLoggedBody is how much of each response body is written to the debug log.
+	maxLoggedBody = 2048
+)
+
+// redactedHeaders lists the headers whose values are never written to the debug log.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// getDebugLogPath returns the default debug log path. It is kept next to the cached token.
+func getDebugLogPath() (string, error) {
+	tokenPath, err := getTokenCachePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(tokenPath), debugLogFileName), nil
+}
+
+// openDebugLog opens the debug log at path for appending and returns a logger
+// writing to it. The log holds budget data, so only the user can read it.
+func openDebugLog(path string) (*slog.Logger, *os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open debug log: %w", err)
+	}
+	logger := slog.New(slog.NewTextHandler(f, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	logger.Info("ynab-export started", slog.String("version", version), slog.Any("args", redactArgs(os.Args[1:])))
+	return logger, f, nil
+}
+
+// redactArgs returns the command-line arguments with the value of --token and the
+// credentials in the --proxy URL hidden.
+func redactArgs(args []string) []string {
+	redacted := slices.Clone(args)
+	for i, arg := range redacted {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		var redact func(string) string
+		switch name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "="); name {
+		case "token", "t":
+			redact = func(string) string { return "[REDACTED]" }
+		case "proxy":
+			redact = redactURL
+		default:
+			continue
+		}
+
+		switch eq := strings.Index(arg, "="); {
+		case eq >= 0:
+			redacted[i] = arg[:eq+1] + redact(arg[eq+1:])
+		case i+1 < len(redacted):
+			redacted[i+1] = redact(redacted[i+1])
+		}
+	}
+	return redacted
+}
+
+// redactURL hides the user name and password of a URL, such as a proxy URL. A value
+// that does not parse as a URL with a host is hidden entirely if it may hold credentials.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	switch {
+	case err == nil && u.User != nil:
+		u.User = url.User("REDACTED")
+		return u.String()
+	case (err != nil || u.Opaque != "") && strings.Contains(s, "@"):
+		return "[REDACTED]"
+	}
+	return s
+}
+
+// loggingTransport is an http.RoundTripper that logs every request and response,
+// with credentials redacted.
+type loggingTransport struct {
+	next   http.RoundTripper
+	logger *slog.Logger
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	start := time.Now()
+	t.logger.LogAttrs(ctx, slog.LevelDebug, "request",
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.Redacted()),
+		headerAttrs(req.Header),
+	)
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.logger.LogAttrs(ctx, slog.LevelWarn, "request failed",
+			slog.String("method", req.Method),
+			slog.String("url", req.URL.Redacted()),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("error", err.Error()),
+		)
+		return nil, err //nolint:wrapcheck // Transparent pass-through transport
+	}
+
+	// The response is logged once its body has been read, so it can include the body
+	resp.Body = &loggedBody{
+		ReadCloser:    resp.Body,
+		transport:     t,
+		resp:          resp,
+		start:         start,
+		timeToHeaders: time.Since(start),
+	}
+	return resp, nil
+}
+
+// loggedBody is a response body that keeps the beginning of the body and logs
+// the response when it is closed.
+type loggedBody struct {
+	io.ReadCloser
+	transport     *loggingTransport
+	resp          *http.Response
+	start         time.Time
+	preview       bytes.Buffer
+	size          int64
+	timeToHeaders time.Duration
+	logged        bool
+}
+
+func (b *loggedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if room := maxLoggedBody - b.preview.Len(); room > 0 {
+		b.preview.Write(p[:min(n, room)])
+	}
+	return n, err //nolint:wrapcheck // Transparent pass-through body
+}
+
+func (b *loggedBody) Close() error {
+	if !b.logged {
+		b.logged = true
+		req := b.resp.Request
+		b.transport.logger.LogAttrs(req.Context(), slog.LevelDebug, "response",
+			slog.String("method", req.Method),
+			slog.String("url", req.URL.Redacted()),
+			slog.Int("status", b.resp.StatusCode),
+			slog.Duration("time_to_headers", b.timeToHeaders),
+			slog.Duration("duration", time.Since(b.start)),
+			headerAttrs(b.resp.Header),
+			slog.Int64("body_bytes_read", b.size),
+			slog.Bool("body_truncated", b.size > int64(b.preview.Len())),
+			slog.String("body", b.preview.String()),
+		)
+	}
+	return b.ReadCloser.Close() //nolint:wrapcheck // Transparent pass-through body
+}
+
+// headerAttrs returns the headers as a log group, with credentials redacted.
+func headerAttrs(header http.Header) slog.Attr {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	attrs := make([]any, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(header.Values(name), ", ")
+		if slices.Contains(redactedHeaders, name) {
+			value = redactCredentials(value)
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.Group("headers", attrs...)
+}
+
+// redactCredentials hides a header value, keeping only an authentication scheme
+// such as "Bearer" so the log still shows what kind of credentials were sent.
+func redactCredentials(value string) string {
+	if scheme, _, ok := strings.Cut(value, " "); ok && !strings.ContainsAny(scheme, "=;") {
+		return scheme + " [REDACTED]"
+	}
+	return "[REDACTED]"
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/charmbracelet/x/term"
+)
+
+// encryptedExt is the file extension added to encrypted exports.
+const encryptedExt = ".age"
+
+// Encryption methods, as shown in export summaries.
+const (
+	encryptionPassphrase = "passphrase"
+	encryptionX25519     = "X25519"
+)
+
+var errNoPassphrase = errors.New("no passphrase given")
+
+// encryption holds the age recipients that exports are encrypted to.
+// The zero value leaves exports unencrypted.
+type encryption struct {
+	recipients []age.Recipient
+	method     string
+}
+
+// newEncryption sets up encryption to the given X25519 recipients, or to a
+// passphrase read from passphraseFile or the YNAB_EXPORT_PASSPHRASE environment
+// variable. Without any of these, exports are not encrypted.
+func newEncryption(recipients []string, passphraseFile string) (encryption, error) {
+	if len(recipients) > 0 && passphraseFile != "" {
+		// age does not allow mixing passphrase and public key recipients
+		return encryption{}, errors.New("--recipient and --passphrase-file cannot be used together")
+	}
+
+	if len(recipients) > 0 {
+		enc := encryption{method: encryptionX25519}
+		for _, r := range recipients {
+			recipient, err := age.ParseX25519Recipient(strings.TrimSpace(r))
+			if err != nil {
+				return encryption{}, fmt.Errorf("invalid recipient %q: %w", r, err)
+			}
+			enc.recipients = append(enc.recipients, recipient)
+		}
+		return enc, nil
+	}
+
+	passphrase, err := readPassphrase(passphraseFile)
+	if err != nil || passphrase == "" {
+		return encryption{}, err
+	}
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return encryption{}, fmt.Errorf("invalid passphrase: %w", err)
+	}
+	return encryption{recipients: []age.Recipient{recipient}, method: encryptionPassphrase}, nil
+}
+
+// enabled reports whether exports are encrypted.
+func (e encryption) enabled() bool {
+	return len(e.recipients) > 0
+}
+
+// ext returns the file extension added for the encryption, including the dot.
+func (e encryption) ext() string {
+	if !e.enabled() {
+		return ""
+	}
+	return encryptedExt
+}
+
+// String describes the encryption for summaries, e.g. "age (passphrase)".
+func (e encryption) String() string {
+	if !e.enabled() {
+		return ""
+	}
+	return "age (" + e.method + ")"
+}
+
+// newWriter wraps w so that data written is encrypted. Closing the returned
+// writer finishes the encrypted stream but does not close w.
+func (e encryption) newWriter(w io.Writer) (io.WriteCloser, error) {
+	if !e.enabled() {
+		return nopWriteCloser{w}, nil
+	}
+	aw, err := age.Encrypt(w, e.recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start encryption: %w", err)
+	}
+	return aw, nil
+}
+
+// readPassphrase returns the first line of path, or the YNAB_EXPORT_PASSPHRASE
+// environment variable if path is empty.
+func readPassphrase(path string) (string, error) {
+	if path == "" {
+		return os.Getenv("YNAB_EXPORT_PASSPHRASE"), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+	defer f.Close() //nolint:errcheck // Read-only file
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+	passphrase := strings.TrimRight(line, "\r\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("%w: %s is empty", errNoPassphrase, path)
+	}
+	return passphrase, nil
+}
+
+// decryptOptions holds the settings of the decrypt command.
+type decryptOptions struct {
+	input          string
+	output         string // Defaults to input without the .age extension; "-" for stdout
+	identityFiles  []string
+	passphraseFile string
+}
+
+// runDecrypt decrypts an encrypted export and returns the exit code.
+func runDecrypt(opts decryptOptions, overwrite overwritePolicy) int {
+	path, err := decryptExport(opts, overwrite)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitCodeFor(err)
+	}
+	if path != stdoutTarget {
+		fmt.Fprintf(os.Stderr, "Decrypted to %s\n", path)
+		fmt.Fprintln(os.Stdout, path)
+	}
+	return exitOK
+}
+
+// decryptExport decrypts opts.input and returns the path it was written to.
+func decryptExport(opts decryptOptions, overwrite overwritePolicy) (string, error) {
+	identities, err := decryptIdentities(opts)
+	if err != nil {
+		return "", err
+	}
+
+	in, err := os.Open(opts.input)
+	if err != nil {
+		return "", fmt.Errorf("failed to open encrypted export: %w", err)
+	}
+	defer in.Close() //nolint:errcheck // Read-only file
+
+	r, err := age.Decrypt(in, identities...)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", opts.input, err)
+	}
+
+	if opts.output == stdoutTarget {
+		if _, err := io.Copy(os.Stdout, r); err != nil {
+			return "", fmt.Errorf("failed to decrypt %s: %w", opts.input, err)
+		}
+		return stdoutTarget, nil
+	}
+
+	output := opts.output
+	if output == "" {
+		trimmed, ok := strings.CutSuffix(opts.input, encryptedExt)
+		if !ok {
+			return "", fmt.Errorf("%s does not end in %s, use --output to name the decrypted file", opts.input, encryptedExt)
+		}
+		output = trimmed
+	}
+
+	f, err := createAtomic(output)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Abort()
+		// Authentication failures surface while reading, so the file is not a write failure
+		return "", fmt.Errorf("failed to decrypt %s: %w", opts.input, err)
+	}
+	return f.Commit(overwrite)
+}
+
+// decryptIdentities loads the identities to decrypt with: the X25519 keys in the
+// identity files, or else a passphrase from a file, the environment or a prompt.
+func decryptIdentities(opts decryptOptions) ([]age.Identity, error) {
+	if len(opts.identityFiles) > 0 {
+		var identities []age.Identity
+		for _, path := range opts.identityFiles {
+			ids, err := parseIdentityFile(path)
+			if err != nil {
+				return nil, err
+			}
+			identities = append(identities, ids...)
+		}
+		return identities, nil
+	}
+
+	passphrase, err := readPassphrase(opts.passphraseFile)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		passphrase, err = promptPassphrase()
+		if err != nil {
+			return nil, err
+		}
+	}
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid passphrase: %w", err)
+	}
+	return []age.Identity{identity}, nil
+}
+
+// parseIdentityFile reads the age identities (private keys) in path.
+func parseIdentityFile(path string) ([]age.Identity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity file: %w", err)
+	}
+	defer f.Close() //nolint:errcheck // Read-only file
+
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity file %s: %w", path, err)
+	}
+	return identities, nil
+}
+
+// promptPassphrase asks for the passphrase on the terminal without echoing it.
+func promptPassphrase() (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", fmt.Errorf("%w: use --identity, --passphrase-file or YNAB_EXPORT_PASSPHRASE", errNoPassphrase)
+	}
+	fmt.Fprint(os.Stderr, "Passphrase: ")
+	passphrase, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if len(passphrase) == 0 {
+		return "", errNoPassphrase
+	}
+	return string(passphrase), nil
+}
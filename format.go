@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// exportFormat is the file format budgets are exported in.
+type exportFormat string
+
+const (
+	formatJSON exportFormat = "json" // The budget as returned by the YNAB API, for Actual Budget
+	formatCSV  exportFormat = "csv"  // Transaction register for spreadsheets
+	formatOFX  exportFormat = "ofx"  // One OFX statement per account
+	formatQIF  exportFormat = "qif"  // Accounts and transactions for GnuCash and Quicken
+
+	// Plain-text accounting journals
+	formatLedger    exportFormat = "ledger"
+	formatHledger   exportFormat = "hledger"
+	formatBeancount exportFormat = "beancount"
+
+	formatSQLite  exportFormat = "sqlite"  // Normalized tables, updated in place
+	formatParquet exportFormat = "parquet" // One file per kind of entity, for analytics
+	formatNDJSON  exportFormat = "ndjson"  // One line per entity, for jq and other stream tools
+)
+
+// exportFormats lists the supported formats, for messages.
+var exportFormats = []exportFormat{formatJSON, formatCSV, formatOFX, formatQIF, formatLedger, formatHledger, formatBeancount, formatSQLite, formatParquet, formatNDJSON}
+
+// parseFormat validates a --format value. An empty value means JSON.
+func parseFormat(s string) (exportFormat, error) {
+	switch f := exportFormat(strings.ToLower(s)); f {
+	case "", formatJSON:
+		return formatJSON, nil
+	case formatCSV, formatOFX, formatQIF, formatLedger, formatHledger, formatBeancount, formatSQLite, formatParquet, formatNDJSON:
+		return f, nil
+	}
+	names := make([]string, len(exportFormats))
+	for i, f := range exportFormats {
+		names[i] = string(f)
+	}
+	return formatJSON, fmt.Errorf("unsupported format %q (use %s)", s, strings.Join(names, ", "))
+}
+
+// ext returns the file extension of the format, including the dot.
+func (f exportFormat) ext() string {
+	if f == formatHledger {
+		return ".journal" // hledger's default journal extension
+	}
+	return "." + string(f)
+}
+
+// write converts a budget to the format and writes it to w. JSON exports are
+// written as downloaded instead, so they have nothing to convert.
+func (f exportFormat) write(w io.Writer, budget budgetDetail, journal journalConfig) error {
+	switch f {
+	case formatCSV:
+		return writeCSV(w, budget)
+	case formatQIF:
+		return writeQIF(w, budget)
+	case formatLedger, formatHledger:
+		return writeJournal(w, budget, journal, dialectLedger)
+	case formatBeancount:
+		return writeJournal(w, budget, journal, dialectBeancount)
+	case formatJSON, formatOFX, formatSQLite, formatParquet, formatNDJSON:
+	}
+	return fmt.Errorf("no converter for format %s", f)
+}
+
+// perAccount reports whether the format writes one file per account instead of
+// one file per budget.
+func (f exportFormat) perAccount() bool {
+	return f == formatOFX
+}
+
+// severalFiles reports whether the format writes several files per budget.
+func (f exportFormat) severalFiles() bool {
+	return f.perAccount() || f == formatParquet
+}
+
+// writeAccount converts one account of a budget to a per-account format and writes it to w.
+func (f exportFormat) writeAccount(w io.Writer, budget budgetDetail, acc account) error {
+	switch f {
+	case formatOFX:
+		return writeOFX(w, budget, acc, time.Now())
+	case formatJSON, formatCSV, formatQIF, formatLedger, formatHledger, formatBeancount, formatSQLite, formatParquet, formatNDJSON:
+	}
+	return fmt.Errorf("format %s is not written per account", f)
+}
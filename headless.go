@@ -0,0 +1,188 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// Exit codes returned by the headless export command.
+const (
+	exitOK             = 0
+	exitFailure        = 1
+	exitUsage          = 2
+	exitAuthFailure    = 3
+	exitBudgetNotFound = 4
+	exitNetworkError   = 5
+	exitWriteFailure   = 6
+	exitRateLimited    = 7
+	exitInterrupted    = 130 // As shells report a process stopped by Ctrl+C
+)
+
+var (
+	errNoToken        = errors.New("no API token provided (use --token or YNAB_API_TOKEN)")
+	errBudgetNotFound = errors.New("budget not found")
+	errAmbiguousName  = errors.New("budget name is ambiguous")
+)
+
+// runExport performs a non-interactive export and returns the process exit code.
+// Progress is written to stderr and the path of each exported file to stdout,
+// unless the export itself is written to stdout.
+func runExport(token string, source TokenSource, opts options) int {
+	// Ctrl+C cancels the requests in flight, so partial downloads are cleaned up
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := headlessExport(ctx, token, opts); err != nil {
+		if ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "Export canceled.\n")
+			return exitInterrupted
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		code := exitCodeFor(err)
+		// If the invalid token was cached, delete it (best effort, ignore errors)
+		if code == exitAuthFailure && source == TokenSourceCached {
+			_ = DeleteCachedToken() //nolint:errcheck // Best effort cleanup, don't block on failure
+		}
+		return code
+	}
+	return exitOK
+}
+
+// headlessExport validates the token, resolves the requested budget and exports it.
+func headlessExport(ctx context.Context, token string, opts options) error {
+	if token == "" {
+		return errNoToken
+	}
+
+	c := newClient(token, opts.api)
+
+	fmt.Fprintf(os.Stderr, "Validating token...\n")
+	if err := c.validateToken(ctx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Fetching budgets...\n")
+	budgets, err := c.listBudgets(ctx)
+	if err != nil {
+		return err
+	}
+
+	if opts.all {
+		warnRateLimit(c, len(budgets))
+		return headlessExportAll(ctx, c, budgets, opts.concurrency, opts.export)
+	}
+
+	selected, err := findBudget(budgets, opts.budget)
+	if err != nil {
+		return err
+	}
+
+	warnRateLimit(c, 1)
+	fmt.Fprintf(os.Stderr, "Exporting budget: %s\n", cmp.Or(selected.Name, selected.ID))
+	result, err := c.downloadBudget(ctx, selected.ID, selected.Name, opts.export, nil)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Export complete (%s, %d transactions, encrypted: %s).\n",
+		formatExportSize(result.summary), result.summary.TransactionCount, formatEncryption(result.summary))
+	if opts.export.sinceLast {
+		fmt.Fprintf(os.Stderr, "Export type: %s\n", formatExportType(result.summary))
+	}
+	if !opts.export.toStdout() {
+		for _, path := range result.paths {
+			fmt.Fprintln(os.Stdout, path)
+		}
+	}
+	return nil
+}
+
+// headlessExportAll exports every budget, reporting each result as it is known.
+// All budgets are attempted; the first failure determines the returned error.
+func headlessExportAll(ctx context.Context, c *client, budgets []budget, concurrency int, exportOpts exportOptions) error {
+	if len(budgets) == 0 {
+		return fmt.Errorf("%w: the account has no budgets", errBudgetNotFound)
+	}
+
+	fmt.Fprintf(os.Stderr, "Exporting %d budgets...\n", len(budgets))
+	startedAt := time.Now()
+	exports := exportBudgets(ctx, c, budgets, concurrency, exportOpts, nil)
+
+	var firstErr error
+	for _, e := range exports {
+		if e.err != nil {
+			fmt.Fprintf(os.Stderr, "  ✗ %s: %v\n", e.budget.Name, e.err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to export %s: %w", e.budget.Name, e.err)
+			}
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "  ✓ %s (%s)\n", e.budget.Name, formatExportSize(e.result.summary))
+		for _, path := range e.result.paths {
+			fmt.Fprintln(os.Stdout, path)
+		}
+	}
+
+	summaryPath, err := writeRunSummary(exports, startedAt, exportOpts)
+	if err != nil {
+		return cmp.Or(firstErr, err)
+	}
+	fmt.Fprintf(os.Stderr, "Run summary written to %s\n", summaryPath)
+
+	return firstErr
+}
+
+// warnRateLimit warns when exporting needs more requests than the rate limit has
+// left this hour.
+func warnRateLimit(c *client, needed int) {
+	now := time.Now()
+	remaining := c.rateLimit.remaining(now)
+	if remaining >= needed {
+		return
+	}
+	wait := formatWait(c.rateLimit.waitFor(needed, now))
+	if c.waitRateLimit {
+		fmt.Fprintf(os.Stderr, "Not enough API requests left this hour (needed: %d, left: %d); waiting for them as needed (up to %s).\n",
+			needed, remaining, wait)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: not enough API requests left this hour (needed: %d, left: %d); more are available in %s.\n",
+		needed, remaining, wait)
+	fmt.Fprintf(os.Stderr, "Use --wait-rate-limit to wait for them instead of failing.\n")
+}
+
+// exitCodeFor maps an export error to the exit code reported to the shell.
+func exitCodeFor(err error) int {
+	var apiErr *apiError
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, errNoToken):
+		return exitAuthFailure
+	case errors.Is(err, errBudgetNotFound), errors.Is(err, errAmbiguousName):
+		return exitBudgetNotFound
+	case errors.Is(err, errWriteExport):
+		return exitWriteFailure
+	case errors.Is(err, errRateLimited):
+		return exitRateLimited
+	case errors.Is(err, errTimeout):
+		return exitNetworkError
+	case errors.As(err, &apiErr):
+		switch apiErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return exitAuthFailure
+		case http.StatusNotFound:
+			return exitBudgetNotFound
+		}
+		return exitFailure
+	case errors.As(err, &urlErr):
+		return exitNetworkError
+	}
+	return exitFailure
+}
@@ -5,6 +5,7 @@ package mockserver
 
 import (
 	"math/rand"
+	"strconv"
 	"time"
 
 	"github.com/go-faker/faker/v4"
@@ -179,7 +180,12 @@ func (g *Generator) GenerateBudgets() []BudgetSummary {
 }
 
 // GenerateBudgetDetail generates a full budget detail for a given budget ID.
+// The special IDs "last-used" and "default" resolve to the most recently modified budget.
 func (g *Generator) GenerateBudgetDetail(budgetID string) *BudgetDetail {
+	if (budgetID == "last-used" || budgetID == "default") && len(g.budgets) > 0 {
+		budgetID = g.budgets[0].Id.String()
+	}
+
 	// Check if we've already generated this budget
 	if detail, ok := g.details[budgetID]; ok {
 		return detail
@@ -208,27 +214,76 @@ func (g *Generator) GenerateBudgetDetail(budgetID string) *BudgetDetail {
 	payees := g.generatePayees(accounts)
 
 	// Generate transactions
-	transactions := g.generateTransactions(accounts, categories, payees)
+	transactions, subtransactions := g.generateTransactions(accounts, categories, payees)
+
+	// Generate budget months, payee locations and scheduled transactions
+	months := g.generateMonths(categories)
+	payeeLocations := generatePayeeLocations(payees)
+	scheduled, scheduledSubtransactions := generateScheduledTransactions(accounts, categories, payees)
 
 	detail := &BudgetDetail{
-		Id:             summary.Id,
-		Name:           summary.Name,
-		LastModifiedOn: summary.LastModifiedOn,
-		FirstMonth:     summary.FirstMonth,
-		LastMonth:      summary.LastMonth,
-		CurrencyFormat: summary.CurrencyFormat,
-		DateFormat:     summary.DateFormat,
-		Accounts:       &accounts,
-		CategoryGroups: &categoryGroups,
-		Categories:     &categories,
-		Payees:         &payees,
-		Transactions:   &transactions,
+		Id:                       summary.Id,
+		Name:                     summary.Name,
+		LastModifiedOn:           summary.LastModifiedOn,
+		FirstMonth:               summary.FirstMonth,
+		LastMonth:                summary.LastMonth,
+		CurrencyFormat:           summary.CurrencyFormat,
+		DateFormat:               summary.DateFormat,
+		Accounts:                 &accounts,
+		CategoryGroups:           &categoryGroups,
+		Categories:               &categories,
+		Payees:                   &payees,
+		PayeeLocations:           &payeeLocations,
+		Months:                   &months,
+		Transactions:             &transactions,
+		Subtransactions:          &subtransactions,
+		ScheduledTransactions:    &scheduled,
+		ScheduledSubtransactions: &scheduledSubtransactions,
 	}
 
 	g.details[budgetID] = detail
 	return detail
 }
 
+// GenerateBudgetDelta generates the changes to a budget since an earlier server
+// knowledge, as returned when last_knowledge_of_server is given. The mock treats
+// transactions from the last week as changed and everything else as unchanged.
+func (g *Generator) GenerateBudgetDelta(budgetID string) *BudgetDetail {
+	detail := g.GenerateBudgetDetail(budgetID)
+	if detail == nil {
+		return nil
+	}
+
+	since := time.Now().AddDate(0, 0, -7)
+	var transactions []TransactionSummary
+	changed := make(map[string]bool)
+	for _, t := range *detail.Transactions {
+		if t.Date.After(since) {
+			transactions = append(transactions, t)
+			changed[t.Id] = true
+		}
+	}
+	var subtransactions []SubTransaction
+	for _, st := range *detail.Subtransactions {
+		if changed[st.TransactionId] {
+			subtransactions = append(subtransactions, st)
+		}
+	}
+
+	delta := *detail
+	delta.Accounts = &[]Account{}
+	delta.CategoryGroups = &[]CategoryGroup{}
+	delta.Categories = &[]Category{}
+	delta.Payees = &[]Payee{}
+	delta.PayeeLocations = &[]PayeeLocation{}
+	delta.Months = &[]MonthDetail{}
+	delta.ScheduledTransactions = &[]ScheduledTransactionSummary{}
+	delta.ScheduledSubtransactions = &[]ScheduledSubTransaction{}
+	delta.Transactions = &transactions
+	delta.Subtransactions = &subtransactions
+	return &delta
+}
+
 func (g *Generator) generateAccounts() []Account {
 	count := g.config.AccountsPerBudget
 	accounts := make([]Account, count)
@@ -329,7 +384,7 @@ func (g *Generator) generatePayees(accounts []Account) []Payee {
 	for i := range accounts {
 		transferAccountID := accounts[i].Id.String()
 		payees = append(payees, Payee{
-			Id:                uuid.New(),
+			Id:                *accounts[i].TransferPayeeId,
 			Name:              "Transfer : " + accounts[i].Name,
 			TransferAccountId: &transferAccountID,
 			Deleted:           false,
@@ -339,16 +394,34 @@ func (g *Generator) generatePayees(accounts []Account) []Payee {
 	return payees
 }
 
+// Shares of generated transactions that are splits, transfers or flagged.
+const (
+	splitRate    = 0.06
+	transferRate = 0.04
+	flagRate     = 0.05
+)
+
+// flagColors lists the colors a generated transaction may be flagged with.
+var flagColors = []TransactionFlagColor{
+	TransactionFlagColorRed, TransactionFlagColorOrange, TransactionFlagColorYellow,
+	TransactionFlagColorGreen, TransactionFlagColorBlue, TransactionFlagColorPurple,
+}
+
 //nolint:gocognit // Complex function generating realistic transaction data
-func (g *Generator) generateTransactions(accounts []Account, categories []Category, payees []Payee) []TransactionSummary {
+func (g *Generator) generateTransactions(accounts []Account, categories []Category, payees []Payee,
+) ([]TransactionSummary, []SubTransaction) {
 	var transactions []TransactionSummary
+	var subtransactions []SubTransaction
 	now := time.Now()
 
 	// Filter to only non-transfer payees for regular transactions
 	regularPayees := make([]Payee, 0)
+	transferPayees := make(map[string]Payee)
 	for _, p := range payees {
 		if p.TransferAccountId == nil {
 			regularPayees = append(regularPayees, p)
+		} else {
+			transferPayees[*p.TransferAccountId] = p
 		}
 	}
 
@@ -360,12 +433,15 @@ func (g *Generator) generateTransactions(accounts []Account, categories []Catego
 		}
 	}
 
-	for accIdx := range accounts {
-		acc := &accounts[accIdx]
-		if acc.Closed || acc.Deleted {
-			continue
+	// Transfers go between accounts that are still open
+	openAccounts := make([]*Account, 0, len(accounts))
+	for i := range accounts {
+		if !accounts[i].Closed && !accounts[i].Deleted {
+			openAccounts = append(openAccounts, &accounts[i])
 		}
+	}
 
+	for _, acc := range openAccounts {
 		for month := 0; month < g.config.MonthsOfHistory; month++ {
 			monthDate := now.AddDate(0, -month, 0)
 
@@ -395,7 +471,7 @@ func (g *Generator) generateTransactions(accounts []Account, categories []Catego
 				}
 
 				payeeID := payee.Id
-				categoryID := category.Id
+				categoryID := &category.Id
 
 				var memo *string
 				if rand.Float32() < 0.2 {
@@ -403,23 +479,173 @@ func (g *Generator) generateTransactions(accounts []Account, categories []Catego
 					memo = &m
 				}
 
-				transactions = append(transactions, TransactionSummary{
+				var flagColor *TransactionFlagColor
+				if rand.Float32() < flagRate {
+					flagColor = &flagColors[rand.Intn(len(flagColors))]
+				}
+
+				txn := TransactionSummary{
 					Id:         txnID,
 					AccountId:  acc.Id,
 					Date:       openapi_types.Date{Time: txnDate},
 					Amount:     amount,
 					PayeeId:    &payeeID,
-					CategoryId: &categoryID,
+					CategoryId: categoryID,
 					Cleared:    cleared,
 					Approved:   true,
 					Deleted:    false,
 					Memo:       memo,
-				})
+					FlagColor:  flagColor,
+				}
+
+				roll := rand.Float32()
+				switch {
+				case roll < transferRate && len(openAccounts) > 1:
+					// A transfer appears in both accounts, each with the other's transfer payee
+					other := openAccounts[rand.Intn(len(openAccounts))]
+					if other == acc {
+						break
+					}
+					otherID := uuid.New().String()
+					fromPayee, toPayee := transferPayees[other.Id.String()].Id, transferPayees[acc.Id.String()].Id
+					amount = -int64(rand.Intn(1000)+1) * 1000
+					txn.Amount, txn.PayeeId, txn.CategoryId, txn.Memo = amount, &fromPayee, nil, nil
+					txn.TransferAccountId, txn.TransferTransactionId = &other.Id, &otherID
+					transactions = append(transactions, TransactionSummary{
+						Id:                    otherID,
+						AccountId:             other.Id,
+						Date:                  txn.Date,
+						Amount:                -amount,
+						PayeeId:               &toPayee,
+						Cleared:               cleared,
+						Approved:              true,
+						TransferAccountId:     &acc.Id,
+						TransferTransactionId: &txnID,
+					})
+				case roll < transferRate+splitRate && amount < 0:
+					// A split has no category of its own; each part has one instead
+					txn.CategoryId = nil
+					parts := 2 + rand.Intn(2)
+					remaining := amount
+					for i := range parts {
+						part := remaining
+						if i < parts-1 {
+							part = remaining / int64(parts-i) / 10 * 10
+						}
+						remaining -= part
+						partCategory := activeCategories[rand.Intn(len(activeCategories))].Id
+						subtransactions = append(subtransactions, SubTransaction{
+							Id:            uuid.New().String(),
+							TransactionId: txnID,
+							Amount:        part,
+							CategoryId:    &partCategory,
+						})
+					}
+				}
+
+				transactions = append(transactions, txn)
 			}
 		}
 	}
 
-	return transactions
+	return transactions, subtransactions
+}
+
+// generateMonths generates a budget month with the amounts of every category for
+// each month of history, newest first as the API returns them.
+func (g *Generator) generateMonths(categories []Category) []MonthDetail {
+	now := time.Now()
+	months := make([]MonthDetail, 0, g.config.MonthsOfHistory)
+	for month := 0; month < g.config.MonthsOfHistory; month++ {
+		monthDate := time.Date(now.Year(), now.Month()-time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		detail := MonthDetail{
+			Month:        openapi_types.Date{Time: monthDate},
+			Income:       int64(rand.Intn(600000)+200000) * 10,
+			ToBeBudgeted: int64(rand.Intn(50000)) * 10,
+			Categories:   make([]Category, 0, len(categories)),
+		}
+		ageOfMoney := int32(rand.Intn(90) + 5)
+		detail.AgeOfMoney = &ageOfMoney
+
+		for _, category := range categories {
+			category.Budgeted = int64(rand.Intn(100000) * 10)
+			category.Activity = int64(-rand.Intn(80000) * 10)
+			category.Balance = category.Budgeted + category.Activity
+			detail.Budgeted += category.Budgeted
+			detail.Activity += category.Activity
+			detail.Categories = append(detail.Categories, category)
+		}
+		months = append(months, detail)
+	}
+	return months
+}
+
+// generatePayeeLocations generates a location for some of the regular payees, as
+// recorded by the YNAB mobile apps.
+func generatePayeeLocations(payees []Payee) []PayeeLocation {
+	var locations []PayeeLocation
+	for _, p := range payees {
+		if p.TransferAccountId != nil || rand.Float32() >= 0.3 {
+			continue
+		}
+		locations = append(locations, PayeeLocation{
+			Id:        uuid.New(),
+			PayeeId:   p.Id,
+			Latitude:  strconv.FormatFloat(faker.Latitude(), 'f', 6, 64),
+			Longitude: strconv.FormatFloat(faker.Longitude(), 'f', 6, 64),
+		})
+	}
+	return locations
+}
+
+// scheduledFrequencies lists the frequencies a generated scheduled transaction may repeat at.
+var scheduledFrequencies = []ScheduledTransactionSummaryFrequency{Weekly, EveryOtherWeek, Monthly, Yearly}
+
+// generateScheduledTransactions generates a few upcoming bills for the open
+// accounts, some of them split between two categories.
+func generateScheduledTransactions(accounts []Account, categories []Category, payees []Payee,
+) ([]ScheduledTransactionSummary, []ScheduledSubTransaction) {
+	var scheduled []ScheduledTransactionSummary
+	var subtransactions []ScheduledSubTransaction
+	now := time.Now()
+
+	for i := range accounts {
+		if accounts[i].Closed || accounts[i].Deleted {
+			continue
+		}
+		for range rand.Intn(3) {
+			id := uuid.New()
+			payee := payees[rand.Intn(len(payeeNames))].Id
+			category := categories[rand.Intn(len(categories))].Id
+			dateFirst := now.AddDate(0, -rand.Intn(12), -rand.Intn(28))
+			txn := ScheduledTransactionSummary{
+				Id:         id,
+				AccountId:  accounts[i].Id,
+				DateFirst:  openapi_types.Date{Time: dateFirst},
+				DateNext:   openapi_types.Date{Time: now.AddDate(0, 0, rand.Intn(30)+1)},
+				Frequency:  scheduledFrequencies[rand.Intn(len(scheduledFrequencies))],
+				Amount:     -int64(rand.Intn(20000)+100) * 10,
+				PayeeId:    &payee,
+				CategoryId: &category,
+			}
+
+			if rand.Float32() < 0.25 {
+				txn.CategoryId = nil
+				part := txn.Amount / 2 / 10 * 10
+				for _, amount := range []int64{part, txn.Amount - part} {
+					partCategory := categories[rand.Intn(len(categories))].Id
+					subtransactions = append(subtransactions, ScheduledSubTransaction{
+						Id:                     uuid.New(),
+						ScheduledTransactionId: id,
+						Amount:                 amount,
+						CategoryId:             &partCategory,
+					})
+				}
+			}
+			scheduled = append(scheduled, txn)
+		}
+	}
+	return scheduled, subtransactions
 }
 
 // GenerateUser generates a mock user.
@@ -7,9 +7,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,6 +21,7 @@ import (
 type MockServer struct {
 	generator *Generator
 	delays    DelayConfig
+	knowledge atomic.Int64 // Server knowledge, advanced on every budget download
 }
 
 // DelayConfig holds per-endpoint delay configuration.
@@ -48,6 +53,80 @@ func parseDuration(s string, defaultVal time.Duration) time.Duration {
 	return d
 }
 
+// LoadErrorRate loads the fraction of requests (0-1) that fail with a 503 error
+// from the YNAB_MOCK_ERROR_RATE environment variable, for testing retries.
+func LoadErrorRate() float64 {
+	rate, err := strconv.ParseFloat(os.Getenv("YNAB_MOCK_ERROR_RATE"), 64)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// withRandomErrors wraps a handler so that a fraction of requests fail with 503 Service Unavailable.
+func withRandomErrors(next http.Handler, rate float64) http.Handler {
+	if rate <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rand.Float64() < rate { //nolint:gosec // G404: math/rand is acceptable for mock errors
+			writeError(w, http.StatusServiceUnavailable, "503", "service_unavailable", "Simulated outage")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LoadRateLimit loads the number of requests allowed before requests fail with
+// 429 Too Many Requests from the YNAB_MOCK_RATE_LIMIT environment variable, for
+// testing rate limiting. Zero disables the limit.
+func LoadRateLimit() int {
+	limit, err := strconv.Atoi(os.Getenv("YNAB_MOCK_RATE_LIMIT"))
+	if err != nil {
+		return 0
+	}
+	return limit
+}
+
+// mockRateLimitBlock is how long requests fail once the mock rate limit is reached.
+const mockRateLimitBlock = 2 * time.Second
+
+// withRateLimit wraps a handler to report usage in the X-Rate-Limit header like
+// the YNAB API does. With a limit, requests beyond it fail with 429 Too Many
+// Requests for a short time, after which the count starts over. The header always
+// reports YNAB's hourly limit, so clients rely on Retry-After to recover.
+func withRateLimit(next http.Handler, limit int) http.Handler {
+	var (
+		mu           sync.Mutex
+		used         int
+		blockedUntil time.Time
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		now := time.Now()
+		if !blockedUntil.IsZero() && !now.Before(blockedUntil) {
+			used, blockedUntil = 0, time.Time{}
+		}
+		if limit > 0 && used >= limit && blockedUntil.IsZero() {
+			blockedUntil = now.Add(mockRateLimitBlock)
+		}
+		blocked := !blockedUntil.IsZero()
+		retryAfter := int(blockedUntil.Sub(now).Seconds()) + 1
+		if !blocked {
+			used++
+		}
+		w.Header().Set("X-Rate-Limit", fmt.Sprintf("%d/200", used))
+		mu.Unlock()
+
+		if blocked {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeError(w, http.StatusTooManyRequests, "429", "too_many_requests", "Too many requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // NewMockServer creates a new mock server with the given configuration.
 func NewMockServer(config MockConfig, delays DelayConfig) *MockServer {
 	gen := NewGenerator(config)
@@ -67,7 +146,8 @@ func StartMockServer() (serverURL string, shutdown func()) {
 	mockServer := NewMockServer(config, delays)
 
 	// Create handler with /v1 base URL to match YNAB API
-	handler := HandlerFromMuxWithBaseURL(mockServer, http.NewServeMux(), "/v1")
+	handler := withRandomErrors(HandlerFromMuxWithBaseURL(mockServer, http.NewServeMux(), "/v1"), LoadErrorRate())
+	handler = withRateLimit(handler, LoadRateLimit())
 
 	// Listen on random port using ListenConfig for proper context support
 	var lc net.ListenConfig
@@ -95,11 +175,20 @@ func StartMockServer() (serverURL string, shutdown func()) {
 
 // writeJSON writes a JSON response.
 func writeJSON(w http.ResponseWriter, status int, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(v); err != nil {
+	body, err := json.Marshal(v)
+	if err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	_, _ = w.Write(body) //nolint:errcheck // Client went away
+}
+
+// writeError writes a YNAB error response.
+func writeError(w http.ResponseWriter, status int, id, name, detail string) {
+	writeJSON(w, status, ErrorResponse{Error: ErrorDetail{Id: id, Name: name, Detail: detail}})
 }
 
 // GetUser implements the /user endpoint.
@@ -132,12 +221,20 @@ func (s *MockServer) GetBudgets(w http.ResponseWriter, _ *http.Request, _ GetBud
 }
 
 // GetBudgetById implements the /budgets/{budget_id} endpoint.
-func (s *MockServer) GetBudgetById(w http.ResponseWriter, _ *http.Request, budgetId string, _ GetBudgetByIdParams) {
+func (s *MockServer) GetBudgetById(w http.ResponseWriter, _ *http.Request, budgetId string, params GetBudgetByIdParams) {
 	time.Sleep(s.delays.Budget)
 
-	detail := s.generator.GenerateBudgetDetail(budgetId)
+	var detail *BudgetDetail
+	knowledge := s.knowledge.Add(1)
+	if params.LastKnowledgeOfServer != nil {
+		detail = s.generator.GenerateBudgetDelta(budgetId)
+		// Knowledge from an earlier run of the mock server may be ahead of this one
+		knowledge = max(knowledge, *params.LastKnowledgeOfServer+1)
+	} else {
+		detail = s.generator.GenerateBudgetDetail(budgetId)
+	}
 	if detail == nil {
-		http.Error(w, "Budget not found", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, "404.2", "resource_not_found", "Resource not found")
 		return
 	}
 
@@ -147,7 +244,7 @@ func (s *MockServer) GetBudgetById(w http.ResponseWriter, _ *http.Request, budge
 			ServerKnowledge int64        `json:"server_knowledge"`
 		}{
 			Budget:          *detail,
-			ServerKnowledge: 1,
+			ServerKnowledge: knowledge,
 		},
 	}
 	writeJSON(w, http.StatusOK, resp)
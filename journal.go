@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// journalConfig maps YNAB accounts and categories to the accounts of a plain-text
+// accounting journal. It is read from the "journal" key of the config file, and
+// every setting is optional.
+type journalConfig struct {
+	Assets        string            `json:"assets"`        // Parent of asset accounts
+	Liabilities   string            `json:"liabilities"`   // Parent of credit cards, loans and other debts
+	Expenses      string            `json:"expenses"`      // Parent of categories, as Group:Category
+	Income        string            `json:"income"`        // Account for inflows to Ready to Assign
+	Uncategorized string            `json:"uncategorized"` // Account for transactions without a category
+	Accounts      map[string]string `json:"accounts"`      // YNAB account name to journal account
+	Categories    map[string]string `json:"categories"`    // "Group:Category" or category name to journal account
+}
+
+// Default journal account hierarchy.
+const (
+	defaultJournalAssets        = "Assets"
+	defaultJournalLiabilities   = "Liabilities"
+	defaultJournalExpenses      = "Expenses"
+	defaultJournalIncome        = "Income:Ready to Assign"
+	defaultJournalUncategorized = "Expenses:Uncategorized"
+)
+
+// beancountComponentPrefix starts Beancount account name components whose
+// first letter has no uppercase form.
+const beancountComponentPrefix = "X-"
+
+// internalCategoryGroup is the YNAB category group of Ready to Assign and Uncategorized.
+const internalCategoryGroup = "Internal Master Category"
+
+// journalDialect is the syntax a journal is written in.
+type journalDialect int
+
+const (
+	dialectLedger    journalDialect = iota // Ledger and hledger
+	dialectBeancount                       // Beancount
+)
+
+// journalPosting is one line of a journal transaction.
+type journalPosting struct {
+	account string
+	amount  int64 // Milliunits
+	comment string
+}
+
+// journalWriter writes a budget as a journal in one dialect.
+type journalWriter struct {
+	w         *bufio.Writer
+	idx       budgetIndex
+	cfg       journalConfig
+	currency  currencyFormat
+	commodity string
+	dialect   journalDialect
+}
+
+// writeJournal writes the budget's transactions to w as a balanced double-entry
+// journal. Each YNAB account and category becomes a journal account, a transfer is
+// written once with a posting to each account, and a split transaction has a
+// posting per split. Memos become comments and flags become tags.
+func writeJournal(w io.Writer, budget budgetDetail, cfg journalConfig, dialect journalDialect) error {
+	cfg.Assets = cmp.Or(cfg.Assets, defaultJournalAssets)
+	cfg.Liabilities = cmp.Or(cfg.Liabilities, defaultJournalLiabilities)
+	cfg.Expenses = cmp.Or(cfg.Expenses, defaultJournalExpenses)
+	cfg.Income = cmp.Or(cfg.Income, defaultJournalIncome)
+	cfg.Uncategorized = cmp.Or(cfg.Uncategorized, defaultJournalUncategorized)
+
+	jw := &journalWriter{
+		w:         bufio.NewWriter(w),
+		idx:       newBudgetIndex(budget),
+		cfg:       cfg,
+		currency:  budget.CurrencyFormat,
+		commodity: cmp.Or(budget.CurrencyFormat.ISOCode, "XXX"),
+		dialect:   dialect,
+	}
+
+	txns := journalTransactions(budget)
+	type entry struct {
+		t        transaction
+		postings []journalPosting
+	}
+	entries := make([]entry, len(txns))
+	var accounts []string
+	for i, t := range txns {
+		entries[i] = entry{t: t, postings: jw.postings(t)}
+		for _, p := range entries[i].postings {
+			if !slices.Contains(accounts, p.account) {
+				accounts = append(accounts, p.account)
+			}
+		}
+	}
+	slices.Sort(accounts)
+
+	opened := time.Now().Format(time.DateOnly)
+	if len(txns) > 0 {
+		opened = txns[0].Date
+	}
+	jw.header(budget.Name, accounts, opened)
+	for _, e := range entries {
+		jw.transaction(e.t, e.postings)
+	}
+
+	// Write errors are sticky in bufio.Writer, so checking Flush is enough
+	if err := jw.w.Flush(); err != nil {
+		return fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	return nil
+}
+
+// journalTransactions returns the transactions to write, in date order. Deleted
+// transactions are left out, and so is one side of each transfer, since a single
+// journal transaction moves the money between both accounts.
+func journalTransactions(budget budgetDetail) []transaction {
+	live := make(map[string]bool, len(budget.Transactions))
+	for _, t := range budget.Transactions {
+		if !t.Deleted {
+			live[t.ID] = true
+		}
+	}
+	splitSide := make(map[string]bool)
+	for _, st := range budget.Subtransactions {
+		if !st.Deleted {
+			splitSide[st.ID] = true
+			splitSide[st.TransactionID] = true
+		}
+	}
+
+	var txns []transaction
+	for _, t := range budget.Transactions {
+		if t.Deleted {
+			continue
+		}
+		// A transfer from a split is written with the split, otherwise by the side with the lower ID
+		if other := t.TransferTransactionID; other != "" && (splitSide[other] || (live[other] && other < t.ID)) {
+			continue
+		}
+		txns = append(txns, t)
+	}
+	slices.SortStableFunc(txns, func(a, b transaction) int { return cmp.Compare(a.Date, b.Date) })
+	return txns
+}
+
+// postings returns the balanced postings of a transaction: the amount in its
+// account, and the opposite amount to its category, transfer account or splits.
+func (jw *journalWriter) postings(t transaction) []journalPosting {
+	postings := []journalPosting{{account: jw.account(jw.idx.accounts[t.AccountID]), amount: t.Amount}}
+
+	parts, split := jw.idx.splits[t.ID]
+	if !split {
+		return append(postings, journalPosting{account: jw.target(t.CategoryID, t.TransferAccountID), amount: -t.Amount})
+	}
+	for _, st := range parts {
+		postings = append(postings, journalPosting{
+			account: jw.target(st.CategoryID, st.TransferAccountID),
+			amount:  -st.Amount,
+			comment: st.Memo,
+		})
+	}
+	return postings
+}
+
+// account returns the journal account of a YNAB account.
+func (jw *journalWriter) account(acc account) string {
+	if mapped, ok := jw.cfg.Accounts[acc.Name]; ok {
+		return jw.accountName(mapped)
+	}
+	parent := jw.cfg.Assets
+	if acc.liability() {
+		parent = jw.cfg.Liabilities
+	}
+	return jw.accountName(parent, cmp.Or(acc.Name, acc.ID))
+}
+
+// target returns the journal account that the money of a transaction or split
+// goes to: its transfer account, or its category.
+func (jw *journalWriter) target(categoryID, transferAccountID string) string {
+	if transferAccountID != "" {
+		return jw.account(jw.idx.accounts[transferAccountID])
+	}
+
+	group, name := jw.idx.category(categoryID)
+	if mapped, ok := jw.cfg.Categories[group+":"+name]; ok {
+		return jw.accountName(mapped)
+	}
+	if mapped, ok := jw.cfg.Categories[name]; ok {
+		return jw.accountName(mapped)
+	}
+	switch {
+	case name == "":
+		return jw.accountName(jw.cfg.Uncategorized)
+	case group == internalCategoryGroup && strings.HasPrefix(name, "Inflow"):
+		return jw.accountName(jw.cfg.Income)
+	case group == internalCategoryGroup:
+		return jw.accountName(jw.cfg.Uncategorized)
+	}
+	return jw.accountName(jw.cfg.Expenses, group, name)
+}
+
+// accountName joins account name parts, which may themselves contain ":"-separated
+// levels, into an account name that is valid in the dialect.
+func (jw *journalWriter) accountName(parts ...string) string {
+	var components []string
+	for _, part := range parts {
+		for component := range strings.SplitSeq(part, ":") {
+			if component = jw.component(component); component != "" {
+				components = append(components, component)
+			}
+		}
+	}
+	return strings.Join(components, ":")
+}
+
+// component makes one level of an account name valid. Ledger allows single
+// spaces but no runs of whitespace; Beancount only allows letters, digits and
+// dashes, starting with a capital letter or digit.
+func (jw *journalWriter) component(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if jw.dialect == dialectLedger {
+		// A leading ( or [ would make the posting virtual
+		return strings.TrimLeft(s, "([")
+	}
+
+	var b strings.Builder
+	dash := false
+	for _, r := range s {
+		switch {
+		case r >= utf8.RuneSelf && !unicode.IsSpace(r) && !unicode.IsPunct(r) && !unicode.IsSymbol(r),
+			'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		default:
+			dash = true
+		}
+	}
+	name := b.String()
+	if name == "" {
+		return ""
+	}
+	first, size := utf8.DecodeRuneInString(name)
+	switch upper := unicode.ToUpper(first); {
+	case '0' <= first && first <= '9', unicode.IsUpper(first):
+		return name
+	case unicode.IsUpper(upper):
+		return string(upper) + name[size:]
+	}
+	// Letters without an uppercase form, such as CJK, need a capital in front
+	return beancountComponentPrefix + name
+}
+
+// header writes the commodity and the accounts used, which both Ledger and
+// Beancount need declared in strict mode.
+func (jw *journalWriter) header(title string, accounts []string, opened string) {
+	digits := max(jw.currency.DecimalDigits, 0)
+	sample := "1000" + strings.Repeat("0", digits)
+	if digits > 0 {
+		sample = "1000." + strings.Repeat("0", digits)
+	}
+
+	switch jw.dialect {
+	case dialectLedger:
+		fmt.Fprintf(jw.w, "; YNAB budget: %s\n\n", jw.text(title))
+		fmt.Fprintf(jw.w, "commodity %s\n    format %s %s\n\n", jw.commodity, sample, jw.commodity)
+		for _, account := range accounts {
+			fmt.Fprintf(jw.w, "account %s\n", account)
+		}
+	case dialectBeancount:
+		fmt.Fprintf(jw.w, "option \"title\" %s\n", jw.quote(title))
+		fmt.Fprintf(jw.w, "option \"operating_currency\" \"%s\"\n\n", jw.commodity)
+		fmt.Fprintf(jw.w, "%s commodity %s\n\n", opened, jw.commodity)
+		for _, account := range accounts {
+			fmt.Fprintf(jw.w, "%s open %s\n", opened, account)
+		}
+	}
+	fmt.Fprint(jw.w, "\n")
+}
+
+// transaction writes one journal transaction.
+func (jw *journalWriter) transaction(t transaction, postings []journalPosting) {
+	// Cleared and reconciled transactions are complete, uncleared ones pending
+	mark := "*"
+	if t.Cleared == "uncleared" {
+		mark = "!"
+	}
+	payee := jw.idx.payees[t.PayeeID]
+	tag := cmp.Or(journalTag(t.FlagName), journalTag(t.FlagColor))
+
+	switch jw.dialect {
+	case dialectLedger:
+		fmt.Fprintf(jw.w, "%s %s %s\n", t.Date, mark, strings.TrimLeft(jw.text(payee), "("))
+		if t.Memo != "" {
+			fmt.Fprintf(jw.w, "    ; %s\n", jw.text(t.Memo))
+		}
+		if tag != "" {
+			fmt.Fprintf(jw.w, "    ; flag: %s\n", tag)
+		}
+	case dialectBeancount:
+		line := fmt.Sprintf("%s %s %s %s", t.Date, mark, jw.quote(payee), jw.quote(""))
+		if tag != "" {
+			line += " #flag-" + tag
+		}
+		fmt.Fprintln(jw.w, line)
+		if t.Memo != "" {
+			fmt.Fprintf(jw.w, "  ; %s\n", jw.text(t.Memo))
+		}
+	}
+
+	width := 0
+	for _, p := range postings {
+		width = max(width, utf8.RuneCountInString(p.account))
+	}
+	for _, p := range postings {
+		amount := jw.currency.exact(p.amount)
+		padding := strings.Repeat(" ", width-utf8.RuneCountInString(p.account)+2+max(12-len(amount), 0))
+		fmt.Fprintf(jw.w, "    %s%s%s %s", p.account, padding, amount, jw.commodity)
+		if p.comment != "" {
+			fmt.Fprintf(jw.w, "  ; %s", jw.text(p.comment))
+		}
+		fmt.Fprint(jw.w, "\n")
+	}
+	fmt.Fprint(jw.w, "\n")
+}
+
+// text makes s fit on one line of the journal.
+func (jw *journalWriter) text(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// quote returns s as a Beancount string.
+func (jw *journalWriter) quote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(jw.text(s))
+	return `"` + s + `"`
+}
+
+// journalTag turns a flag name or color into a tag, which Beancount only allows
+// ASCII letters, digits, dashes and underscores in.
+func journalTag(flag string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range flag {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '_':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		default:
+			dash = true
+		}
+	}
+	return b.String()
+}
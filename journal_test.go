@@ -0,0 +1,191 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteJournal(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect journalDialect
+		want    string
+	}{
+		{
+			name:    "ledger",
+			dialect: dialectLedger,
+			want: `; YNAB budget: Test Budget
+
+commodity USD
+    format 1000.00 USD
+
+account Assets:Checking
+account Assets:Savings
+account Expenses:Bills:Rent
+account Expenses:Food:Groceries
+account Income:Ready to Assign
+account Liabilities:Visa
+
+2025-01-03 * Grocer
+    ; Weekly shop
+    ; flag: red
+    Assets:Checking                -45.67 USD
+    Expenses:Food:Groceries         45.67 USD
+
+2025-01-05 * Employer
+    Assets:Checking              2000.00 USD
+    Income:Ready to Assign      -2000.00 USD
+
+2025-01-08 ! Grocer
+    Liabilities:Visa               -12.34 USD
+    Expenses:Food:Groceries         12.34 USD
+
+2025-01-10 * Grocer
+    ; Big shop
+    ; flag: Review
+    Assets:Checking               -120.00 USD
+    Expenses:Food:Groceries         80.00 USD  ; Food
+    Expenses:Bills:Rent             40.00 USD
+
+2025-01-12 * Transfer : Checking
+    Assets:Savings         500.00 USD
+    Assets:Checking       -500.00 USD
+
+`,
+		},
+		{
+			name:    "beancount",
+			dialect: dialectBeancount,
+			want: `option "title" "Test Budget"
+option "operating_currency" "USD"
+
+2025-01-03 commodity USD
+
+2025-01-03 open Assets:Checking
+2025-01-03 open Assets:Savings
+2025-01-03 open Expenses:Bills:Rent
+2025-01-03 open Expenses:Food:Groceries
+2025-01-03 open Income:Ready-to-Assign
+2025-01-03 open Liabilities:Visa
+
+2025-01-03 * "Grocer" "" #flag-red
+  ; Weekly shop
+    Assets:Checking                -45.67 USD
+    Expenses:Food:Groceries         45.67 USD
+
+2025-01-05 * "Employer" ""
+    Assets:Checking              2000.00 USD
+    Income:Ready-to-Assign      -2000.00 USD
+
+2025-01-08 ! "Grocer" ""
+    Liabilities:Visa               -12.34 USD
+    Expenses:Food:Groceries         12.34 USD
+
+2025-01-10 * "Grocer" "" #flag-Review
+  ; Big shop
+    Assets:Checking               -120.00 USD
+    Expenses:Food:Groceries         80.00 USD  ; Food
+    Expenses:Bills:Rent             40.00 USD
+
+2025-01-12 * "Transfer : Checking" ""
+    Assets:Savings         500.00 USD
+    Assets:Checking       -500.00 USD
+
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := writeJournal(&b, testBudget(), journalConfig{}, tt.dialect); err != nil {
+				t.Fatalf("writeJournal() error = %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("writeJournal() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJournalAccountNames(t *testing.T) {
+	budget := testBudget()
+	budget.Accounts[0].Name = "école"
+	budget.Categories[2].Name = "日本 food"
+	cfg := journalConfig{Accounts: map[string]string{"Visa": "Liabilities:Cards:visa"}}
+
+	tests := []struct {
+		name    string
+		dialect journalDialect
+		want    []string
+	}{
+		{
+			name:    "ledger keeps names",
+			dialect: dialectLedger,
+			want:    []string{"account Assets:école\n", "account Expenses:Food:日本 food\n", "account Liabilities:Cards:visa\n"},
+		},
+		{
+			name:    "beancount capitalizes names",
+			dialect: dialectBeancount,
+			want:    []string{" open Assets:École\n", " open Expenses:Food:X-日本-food\n", " open Liabilities:Cards:Visa\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := writeJournal(&b, budget, cfg, tt.dialect); err != nil {
+				t.Fatalf("writeJournal() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(b.String(), want) {
+					t.Errorf("writeJournal() does not contain %q:\n%s", want, b.String())
+				}
+			}
+		})
+	}
+}
+
+func TestJournalComponent(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect journalDialect
+		s       string
+		want    string
+	}{
+		{name: "ledger collapses whitespace", dialect: dialectLedger, s: "  Dining   Out ", want: "Dining Out"},
+		{name: "ledger strips virtual brackets", dialect: dialectLedger, s: "(Savings)", want: "Savings)"},
+		{name: "beancount dashes", dialect: dialectBeancount, s: "Dining & Out", want: "Dining-Out"},
+		{name: "beancount ascii lowercase", dialect: dialectBeancount, s: "groceries", want: "Groceries"},
+		{name: "beancount accented lowercase", dialect: dialectBeancount, s: "école", want: "École"},
+		{name: "beancount greek lowercase", dialect: dialectBeancount, s: "ταξίδια", want: "Ταξίδια"},
+		{name: "beancount digit", dialect: dialectBeancount, s: "401k", want: "401k"},
+		{name: "beancount uncased letter", dialect: dialectBeancount, s: "日本", want: "X-日本"},
+		{name: "beancount leading punctuation", dialect: dialectBeancount, s: "- rent", want: "Rent"},
+		{name: "beancount nothing left", dialect: dialectBeancount, s: "🎉 !", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jw := &journalWriter{dialect: tt.dialect}
+			if got := jw.component(tt.s); got != tt.want {
+				t.Errorf("component(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJournalTag(t *testing.T) {
+	tests := []struct {
+		flag string
+		want string
+	}{
+		{flag: "red", want: "red"},
+		{flag: "Needs Review", want: "Needs-Review"},
+		{flag: "tax_2024", want: "tax_2024"},
+		{flag: "  café!", want: "caf"},
+		{flag: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := journalTag(tt.flag); got != tt.want {
+			t.Errorf("journalTag(%q) = %q, want %q", tt.flag, got, tt.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json/jsontext"
 	"encoding/json/v2"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -68,8 +69,46 @@ func (obj *OrderedObject[V]) UnmarshalJSONFrom(dec *jsontext.Decoder) error {
 	return nil
 }
 
+// Get returns the value of the member with the given name.
+func (obj OrderedObject[V]) Get(name string) (V, bool) {
+	for _, member := range obj {
+		if member.Name == name {
+			return member.Value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Set replaces the value of the member with the given name, or appends a new
+// member if there is none.
+func (obj *OrderedObject[V]) Set(name string, value V) {
+	for i := range *obj {
+		if (*obj)[i].Name == name {
+			(*obj)[i].Value = value
+			return
+		}
+	}
+	*obj = append(*obj, ObjectMember[V]{Name: name, Value: value})
+}
+
+// describeBudget reads an exported budget and describes each member of data.budget,
+// in their original order.
+func describeBudget(r io.Reader) (OrderedObject[string], error) {
+	keys, budget, err := extractBudgetKeysAndValues(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(OrderedObject[string], len(keys))
+	for i, key := range keys {
+		fields[i] = ObjectMember[string]{Name: key, Value: inspectJSONValue(budget[key])}
+	}
+	return fields, nil
+}
+
 // extractBudgetKeysAndValues extracts the keys and values from data.budget in their original order.
-func extractBudgetKeysAndValues(jsonData []byte) ([]string, map[string]any, error) {
+func extractBudgetKeysAndValues(r io.Reader) ([]string, map[string]any, error) {
 	// Parse the outer structure with ordered budget
 	type DataWrapper struct {
 		Data struct {
@@ -78,7 +117,7 @@ func extractBudgetKeysAndValues(jsonData []byte) ([]string, map[string]any, erro
 	}
 
 	var wrapper DataWrapper
-	if err := json.Unmarshal(jsonData, &wrapper); err != nil {
+	if err := json.UnmarshalRead(r, &wrapper); err != nil {
 		return nil, nil, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
@@ -103,20 +142,11 @@ func inspectJSONValue(v any) string {
 		}
 		return fmt.Sprintf("{record %d fields}", fieldCount)
 	case []any:
-		itemCount := len(val)
-		// Check if it's a table (array of objects) or a list (array of primitives)
-		if itemCount > 0 {
-			if _, isMap := val[0].(map[string]any); isMap {
-				if itemCount == 1 {
-					return fmt.Sprint(val)
-				}
-				return fmt.Sprintf("[table %d rows]", itemCount)
-			}
-		}
-		if itemCount == 1 {
-			return fmt.Sprint(val)
+		var first any
+		if len(val) > 0 {
+			first = val[0]
 		}
-		return fmt.Sprintf("[list %d items]", itemCount)
+		return inspectJSONArray(first, len(val))
 	case string:
 		return formatMonthYear(val)
 	case float64:
@@ -133,3 +163,17 @@ func inspectJSONValue(v any) string {
 		return fmt.Sprintf("%v", val)
 	}
 }
+
+// inspectJSONArray returns a Nushell-style description of a JSON array from its
+// first element and length, so arrays read one element at a time can be described
+// without keeping them.
+func inspectJSONArray(first any, itemCount int) string {
+	if itemCount == 1 {
+		return fmt.Sprint([]any{first})
+	}
+	// Check if it's a table (array of objects) or a list (array of primitives)
+	if _, isMap := first.(map[string]any); isMap {
+		return fmt.Sprintf("[table %d rows]", itemCount)
+	}
+	return fmt.Sprintf("[list %d items]", itemCount)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const knowledgeFileName = "knowledge.json"
+
+// budgetKnowledge records the server knowledge of a budget's last export.
+type budgetKnowledge struct {
+	ServerKnowledge int64     `json:"server_knowledge"`
+	ExportedAt      time.Time `json:"exported_at"`
+}
+
+// knowledgeMu serializes updates to the knowledge file when budgets are exported concurrently.
+var knowledgeMu sync.Mutex
+
+// getKnowledgePath returns the path to the file recording the server knowledge
+// of each exported budget. It is kept next to the cached token.
+func getKnowledgePath() (string, error) {
+	tokenPath, err := getTokenCachePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(tokenPath), knowledgeFileName), nil
+}
+
+// loadKnowledge reads the recorded server knowledge, keyed by budget ID.
+// Returns an empty map if nothing has been recorded yet.
+func loadKnowledge() (map[string]budgetKnowledge, error) {
+	knowledge := make(map[string]budgetKnowledge)
+
+	path, err := getKnowledgePath()
+	if err != nil {
+		return knowledge, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return knowledge, nil // Nothing exported yet
+		}
+		return knowledge, fmt.Errorf("failed to read server knowledge from %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &knowledge); err != nil {
+		return knowledge, fmt.Errorf("invalid server knowledge file %s: %w", path, err)
+	}
+	return knowledge, nil
+}
+
+// lastKnowledge returns the server knowledge recorded for a budget, if any.
+func lastKnowledge(budgetID string) (int64, bool, error) {
+	knowledgeMu.Lock()
+	defer knowledgeMu.Unlock()
+
+	knowledge, err := loadKnowledge()
+	if err != nil {
+		return 0, false, err
+	}
+	k, ok := knowledge[budgetID]
+	return k.ServerKnowledge, ok, nil
+}
+
+// saveKnowledge records the server knowledge of a budget that was just exported.
+func saveKnowledge(budgetID string, serverKnowledge int64) error {
+	knowledgeMu.Lock()
+	defer knowledgeMu.Unlock()
+
+	knowledge, err := loadKnowledge()
+	if err != nil {
+		return err
+	}
+	knowledge[budgetID] = budgetKnowledge{ServerKnowledge: serverKnowledge, ExportedAt: time.Now()}
+
+	data, err := json.Marshal(knowledge, jsontext.WithIndent("  "), json.Deterministic(true))
+	if err != nil {
+		return fmt.Errorf("failed to encode server knowledge: %w", err)
+	}
+
+	path, err := getKnowledgePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if _, err := writeFileAtomic(path, data, overwriteReplace); err != nil {
+		return fmt.Errorf("failed to save server knowledge: %w", err)
+	}
+	return nil
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 
 	"github.com/StephenBrown2/ynab-export/internal/mockserver"
@@ -18,31 +20,97 @@ var (
 
 const envTrue = "true"
 
-func main() {
-	// Define command-line flags
-	showVersion := flag.Bool("version", false, "show version information")
-	tokenFlag := flag.String("token", "", "YNAB API token (overrides environment variable and cached token)")
+// Subcommands.
+const (
+	commandExport  = "export"
+	commandDecrypt = "decrypt"
+	commandMerge   = "merge"
+)
 
-	// Short flag aliases
-	flag.BoolVar(showVersion, "v", false, "show version information (shorthand)")
-	flag.StringVar(tokenFlag, "t", "", "YNAB API token (shorthand)")
+// options holds the parsed command-line flags.
+type options struct {
+	command        string
+	token          string
+	budget         string
+	compress       string
+	passphraseFile string
+	recipients     []string
+	api            clientOptions
+	export         exportOptions
+	decrypt        decryptOptions
+	merge          mergeOptions
+	concurrency    int
+	showVersion    bool
+	debug          bool
+	all            bool
+	overwrite      bool
+	noClobber      bool
+}
 
-	flag.Parse()
+func main() {
+	opts, err := parseArgs(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(exitOK)
+	}
+	if errors.Is(err, errInvalidFlags) {
+		os.Exit(exitUsage) // Already reported by the flag package
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
 
 	// Check for version flag
-	if *showVersion {
+	if opts.showVersion {
 		fmt.Fprintf(os.Stderr, "ynab-export version %s\n", version)
 		fmt.Fprintf(os.Stderr, "commit: %s\n", commit)
 		fmt.Fprintf(os.Stderr, "built: %s\n", date)
 		os.Exit(0)
 	}
 
+	// Decrypting and merging need neither the API nor the export settings
+	switch opts.command {
+	case commandDecrypt:
+		os.Exit(runDecrypt(opts.decrypt, opts.export.overwrite))
+	case commandMerge:
+		os.Exit(runMerge(opts.merge, opts.export))
+	}
+
+	// Fill in export settings from the environment and config file
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if err := opts.export.resolve(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	closeLog, err := setupDebugLog(&opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if err := opts.api.resolve(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	opts.export.encrypt, err = newEncryption(opts.recipients, opts.passphraseFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if opts.export.format == formatSQLite && opts.export.encrypt.enabled() {
+		fmt.Fprintln(os.Stderr, "Error: the sqlite format updates a database file in place, so it cannot be encrypted")
+		os.Exit(exitUsage)
+	}
+
 	// Check for demo mode
 	var shutdownMock func()
 	if os.Getenv("YNAB_DEMO_MODE") == envTrue {
 		serverURL, shutdown := mockserver.StartMockServer()
 		shutdownMock = shutdown
-		ynabAPIBase = serverURL + "/v1"
+		opts.api.baseURL = serverURL + "/v1"
 		if os.Getenv("YNAB_DEMO_QUIET") != envTrue {
 			fmt.Fprintf(os.Stderr, "Demo mode enabled. Using mock YNAB API at %s\n", serverURL)
 		}
@@ -50,24 +118,237 @@ func main() {
 	fmt.Fprintf(os.Stderr, "\n") // Separate TUI output from prompt
 
 	// Determine token and its source (priority: flag > env > cached)
-	token, source := resolveToken(*tokenFlag)
+	token, source := resolveToken(opts.token)
 
-	// Launch TUI and run
-	exitCode := runTUI(token, source)
+	// Run the headless export or launch the TUI
+	var exitCode int
+	switch opts.command {
+	case commandExport:
+		exitCode = runExport(token, source, opts)
+	default:
+		exitCode = runTUI(token, source, opts)
+	}
 
 	// Cleanup mock server if running
 	if shutdownMock != nil {
 		shutdownMock()
 	}
+	closeLog(exitCode)
 
 	if exitCode != 0 {
 		os.Exit(exitCode)
 	}
 }
 
+// errInvalidFlags is returned by parseArgs after the flag package has printed a parse error.
+var errInvalidFlags = errors.New("invalid flags")
+
+// parseArgs parses the global flags and an optional subcommand with its own flags.
+func parseArgs(args []string) (options, error) {
+	var opts options
+
+	// Both flag sets are registered before parsing, since registering a flag resets it to its default
+	global := flag.NewFlagSet("ynab-export", flag.ContinueOnError)
+	registerCommonFlags(global, &opts)
+
+	export := flag.NewFlagSet("ynab-export export", flag.ContinueOnError)
+	registerCommonFlags(export, &opts)
+
+	decrypt := flag.NewFlagSet("ynab-export decrypt", flag.ContinueOnError)
+	registerDecryptFlags(decrypt, &opts)
+
+	merge := flag.NewFlagSet("ynab-export merge", flag.ContinueOnError)
+	registerMergeFlags(merge, &opts)
+
+	if err := global.Parse(args); err != nil {
+		return opts, flagParseError(err)
+	}
+
+	if global.NArg() > 0 {
+		switch global.Arg(0) {
+		case commandExport:
+			opts.command = commandExport
+			if err := export.Parse(global.Args()[1:]); err != nil {
+				return opts, flagParseError(err)
+			}
+			if export.NArg() > 0 {
+				return opts, fmt.Errorf("unexpected arguments: %v", export.Args())
+			}
+			if opts.budget == "" && !opts.all {
+				return opts, errors.New("export requires --budget or --all")
+			}
+		case commandDecrypt:
+			opts.command = commandDecrypt
+			if err := decrypt.Parse(global.Args()[1:]); err != nil {
+				return opts, flagParseError(err)
+			}
+			if decrypt.NArg() != 1 {
+				return opts, errors.New("decrypt requires exactly one encrypted export file")
+			}
+			opts.decrypt.input = decrypt.Arg(0)
+		case commandMerge:
+			opts.command = commandMerge
+			if err := merge.Parse(global.Args()[1:]); err != nil {
+				return opts, flagParseError(err)
+			}
+			if merge.NArg() < 2 {
+				return opts, errors.New("merge requires a full export followed by one or more delta exports")
+			}
+			opts.merge.inputs = merge.Args()
+		default:
+			return opts, fmt.Errorf("unknown command %q", global.Arg(0))
+		}
+	}
+
+	if opts.all && opts.budget != "" {
+		return opts, errors.New("--all and --budget cannot be used together")
+	}
+	compress, err := parseCompression(opts.compress)
+	if err != nil {
+		return opts, err
+	}
+	opts.export.compress = compress
+
+	switch {
+	case opts.overwrite && opts.noClobber:
+		return opts, errors.New("--overwrite and --no-clobber cannot be used together")
+	case opts.overwrite:
+		opts.export.overwrite = overwriteReplace
+	case opts.noClobber:
+		opts.export.overwrite = overwriteNoClobber
+	}
+	if opts.all && opts.export.output != "" {
+		return opts, errors.New("--output can only be used with a single budget, use --output-dir with --all")
+	}
+
+	return opts, nil
+}
+
+// flagParseError converts a flag set parse error, which the flag package has
+// already printed along with the usage, into errInvalidFlags.
+func flagParseError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return flag.ErrHelp
+	}
+	return errInvalidFlags
+}
+
+// registerCommonFlags defines the flags shared by the TUI and all subcommands.
+func registerCommonFlags(fs *flag.FlagSet, opts *options) {
+	fs.BoolVar(&opts.showVersion, "version", false, "show version information")
+	fs.StringVar(&opts.token, "token", "", "YNAB API token (overrides environment variable and cached token)")
+	fs.StringVar(&opts.budget, "budget", "", `budget to export: ID, name, "last-used" or "default"`)
+	fs.BoolVar(&opts.all, "all", false, "export every budget")
+	fs.IntVar(&opts.concurrency, "concurrency", defaultConcurrency, "number of budgets to download at once with --all")
+	fs.StringVar(&opts.export.dir, "output-dir", "", "directory to write exports to (default ~/Downloads)")
+	fs.StringVar(&opts.export.output, "output", "", `file to write the export to, or "-" for stdout (overrides --output-dir)`)
+	fs.StringVar(&opts.export.output, "o", "", "file to write the export to (shorthand)")
+	fs.Func("format", "export format: json (for Actual Budget), csv (transaction register), ofx (one file per account), qif, ledger, hledger, beancount, sqlite, parquet (one file per record kind) or ndjson (one line per record)", func(s string) error {
+		opts.export.format = exportFormat(s)
+		return nil
+	})
+	fs.StringVar(&opts.compress, "compress", "", "compress the export: gzip or zstd")
+	fs.BoolVar(&opts.overwrite, "overwrite", false, "replace export files that already exist")
+	fs.BoolVar(&opts.noClobber, "no-clobber", false, "fail instead of writing when the export file already exists")
+	fs.StringVar(&opts.api.caCert, "ca-cert", "", "trust the CA certificates in this PEM file, e.g. of a TLS-inspecting proxy")
+	fs.StringVar(&opts.api.clientCert, "client-cert", "", "authenticate with the client certificate in this PEM file")
+	fs.StringVar(&opts.api.clientKey, "client-key", "", "private key for --client-cert, if not in the same file")
+	fs.StringVar(&opts.api.proxy, "proxy", "", "send requests through this proxy URL (default from HTTPS_PROXY)")
+	fs.DurationVar(&opts.api.timeout, "timeout", 0,
+		"give up on a request when nothing is received from YNAB for this long (default 30s)")
+	fs.BoolVar(&opts.api.waitRateLimit, "wait-rate-limit", false,
+		"wait when the YNAB API rate limit (200 requests per hour) is reached, instead of failing")
+	fs.BoolVar(&opts.debug, "debug", false,
+		"log every API request and response, with the token redacted, to "+debugLogFileName+" in the token cache directory")
+	fs.StringVar(&opts.api.logFile, "log-file", "", "write the --debug log to this file instead (implies --debug)")
+	fs.BoolVar(&opts.export.sinceLast, "since-last", false,
+		"only export what changed since the budget's last export, as a -delta file")
+	fs.Func("recipient", "encrypt the export with age to this X25519 public key (age1...); may be repeated", func(s string) error {
+		opts.recipients = append(opts.recipients, s)
+		return nil
+	})
+	fs.StringVar(&opts.passphraseFile, "passphrase-file", "",
+		"encrypt the export with age using the passphrase in this file (or set YNAB_EXPORT_PASSPHRASE)")
+	fs.StringVar(&opts.export.filenameTemplate, "filename-template", "",
+		"export file name without extension; placeholders: {budget_name}, {budget_id}, {date}, {time}, {server_knowledge}, {currency}")
+
+	// Short flag aliases
+	fs.BoolVar(&opts.showVersion, "v", false, "show version information (shorthand)")
+	fs.StringVar(&opts.token, "t", "", "YNAB API token (shorthand)")
+	fs.StringVar(&opts.budget, "b", "", "budget to export (shorthand)")
+}
+
+// registerDecryptFlags defines the flags of the decrypt command.
+func registerDecryptFlags(fs *flag.FlagSet, opts *options) {
+	fs.Func("identity", "age identity (private key) file to decrypt with; may be repeated", func(s string) error {
+		opts.decrypt.identityFiles = append(opts.decrypt.identityFiles, s)
+		return nil
+	})
+	fs.StringVar(&opts.decrypt.passphraseFile, "passphrase-file", "",
+		"file containing the passphrase (default YNAB_EXPORT_PASSPHRASE, or prompt)")
+	fs.StringVar(&opts.decrypt.output, "output", "", `file to write the decrypted export to, or "-" for stdout (default: input without .age)`)
+	fs.StringVar(&opts.decrypt.output, "o", "", "file to write the decrypted export to (shorthand)")
+	fs.BoolVar(&opts.overwrite, "overwrite", false, "replace the decrypted file if it already exists")
+	fs.BoolVar(&opts.noClobber, "no-clobber", false, "fail instead of writing when the decrypted file already exists")
+
+	// Short flag aliases
+	fs.Func("i", "age identity file (shorthand)", func(s string) error {
+		opts.decrypt.identityFiles = append(opts.decrypt.identityFiles, s)
+		return nil
+	})
+}
+
+// registerMergeFlags defines the flags of the merge command.
+func registerMergeFlags(fs *flag.FlagSet, opts *options) {
+	fs.StringVar(&opts.merge.output, "output", stdoutTarget, `file to write the merged export to, or "-" for stdout`)
+	fs.StringVar(&opts.merge.output, "o", stdoutTarget, "file to write the merged export to (shorthand)")
+	fs.StringVar(&opts.compress, "compress", "", "compress the merged export: gzip or zstd")
+	fs.BoolVar(&opts.overwrite, "overwrite", false, "replace the merged file if it already exists")
+	fs.BoolVar(&opts.noClobber, "no-clobber", false, "fail instead of writing when the merged file already exists")
+}
+
+// setupDebugLog opens the debug log if --debug or --log-file was given and
+// attaches it to the API client options. The returned function records the exit
+// code and closes the log.
+func setupDebugLog(opts *options) (func(exitCode int), error) {
+	if !opts.debug && opts.api.logFile == "" {
+		return func(int) {}, nil
+	}
+
+	if opts.api.logFile == "" {
+		path, err := getDebugLogPath()
+		if err != nil {
+			return nil, err
+		}
+		opts.api.logFile = path
+	}
+	path, err := expandHome(opts.api.logFile)
+	if err != nil {
+		return nil, err
+	}
+	opts.api.logFile = path
+
+	logger, f, err := openDebugLog(path)
+	if err != nil {
+		return nil, err
+	}
+	opts.api.logger = logger
+	fmt.Fprintf(os.Stderr, "Logging API requests to %s\n", path)
+
+	return func(exitCode int) {
+		logger.Info("ynab-export finished", slog.Int("exit_code", exitCode))
+		_ = f.Close() //nolint:errcheck // Every record has already been written
+	}, nil
+}
+
 // runTUI launches the terminal UI and returns exit code.
-func runTUI(token string, source TokenSource) int {
-	p := tea.NewProgram(initialModel(token, source))
+func runTUI(token string, source TokenSource, opts options) int {
+	var programOpts []tea.ProgramOption
+	if opts.export.toStdout() {
+		// Keep stdout clean for the exported JSON; the UI is drawn on stderr instead
+		programOpts = append(programOpts, tea.WithOutput(os.Stderr))
+	}
+	p := tea.NewProgram(initialModel(token, source, opts), programOpts...)
 	if _, err := p.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		return 1
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"os"
+)
+
+// budgetDocument is an exported budget file, as returned by the /budgets/{id} endpoint.
+type budgetDocument struct {
+	Data struct {
+		Budget          OrderedObject[jsontext.Value] `json:"budget"`
+		ServerKnowledge int64                         `json:"server_knowledge"`
+	} `json:"data"`
+}
+
+// mergeOptions holds the settings of the merge command.
+type mergeOptions struct {
+	inputs []string // Full export followed by deltas, oldest first
+	output string   // Defaults to "-" for stdout
+}
+
+// runMerge applies delta exports to a full export and returns the exit code.
+func runMerge(opts mergeOptions, exportOpts exportOptions) int {
+	path, err := mergeExports(opts, exportOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitCodeFor(err)
+	}
+	if path != stdoutTarget {
+		fmt.Fprintf(os.Stderr, "Merged export written to %s\n", path)
+		fmt.Fprintln(os.Stdout, path)
+	}
+	return exitOK
+}
+
+// mergeExports merges the input files into a full budget export and writes it to
+// opts.output, returning the final path.
+func mergeExports(opts mergeOptions, exportOpts exportOptions) (string, error) {
+	merged, err := readBudgetDocument(opts.inputs[0])
+	if err != nil {
+		return "", err
+	}
+	budgetID, _ := merged.Data.Budget.Get("id")
+
+	for _, path := range opts.inputs[1:] {
+		delta, err := readBudgetDocument(path)
+		if err != nil {
+			return "", err
+		}
+
+		// Deltas only make sense on top of the same budget, in the order they were exported
+		if id, _ := delta.Data.Budget.Get("id"); !bytes.Equal(id, budgetID) {
+			return "", fmt.Errorf("%s is an export of budget %s, not %s", path, id, budgetID)
+		}
+		// A delta exported when nothing had changed keeps the same server knowledge
+		if delta.Data.ServerKnowledge < merged.Data.ServerKnowledge {
+			return "", fmt.Errorf("%s (server knowledge %d) is older than the exports before it (server knowledge %d), list deltas oldest first",
+				path, delta.Data.ServerKnowledge, merged.Data.ServerKnowledge)
+		}
+
+		budget, err := mergeObject(merged.Data.Budget, delta.Data.Budget)
+		if err != nil {
+			return "", fmt.Errorf("failed to merge %s: %w", path, err)
+		}
+		merged.Data.Budget = budget
+		merged.Data.ServerKnowledge = delta.Data.ServerKnowledge
+	}
+
+	data, err := json.Marshal(&merged)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode merged budget: %w", err)
+	}
+
+	exportOpts.output = opts.output
+	path, _, err := exportOpts.writeExport(opts.output, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(os.Stderr, "Applied %d deltas (server knowledge %d).\n",
+		len(opts.inputs)-1, merged.Data.ServerKnowledge)
+	return path, nil
+}
+
+// readBudgetDocument reads an exported budget file, decompressing it if needed.
+func readBudgetDocument(path string) (budgetDocument, error) {
+	var doc budgetDocument
+
+	f, err := os.Open(path)
+	if err != nil {
+		return doc, fmt.Errorf("failed to open export: %w", err)
+	}
+	defer f.Close() //nolint:errcheck // Read-only file
+
+	r, err := newDecompressor(f)
+	if err != nil {
+		return doc, fmt.Errorf("%s: %w", path, err)
+	}
+	defer r.Close() //nolint:errcheck // Read-only stream
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return doc, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return doc, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if _, ok := doc.Data.Budget.Get("id"); !ok {
+		return doc, fmt.Errorf("%s is not a YNAB budget export", path)
+	}
+	return doc, nil
+}
+
+// mergeObject applies the members of delta to base. Arrays of entities are merged
+// entity by entity, and any other value in delta replaces the one in base.
+func mergeObject(base, delta OrderedObject[jsontext.Value]) (OrderedObject[jsontext.Value], error) {
+	merged := append(OrderedObject[jsontext.Value](nil), base...)
+	for _, member := range delta {
+		value := member.Value
+		if value.Kind() == '[' {
+			current, _ := merged.Get(member.Name)
+			var err error
+			value, err = mergeEntities(current, value, entityKey(member.Name))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", member.Name, err)
+			}
+		}
+		merged.Set(member.Name, value)
+	}
+	return merged, nil
+}
+
+// entityKey returns the member that identifies the entities in the named array.
+func entityKey(arrayName string) string {
+	if arrayName == "months" {
+		return "month"
+	}
+	return "id"
+}
+
+// mergeEntities upserts the entities of the delta array into the base array by
+// their key, merging changed entities member by member so nested arrays (such as
+// the categories of a month) are merged too. Entities marked deleted are removed.
+// Arrays of anything other than objects are replaced as a whole.
+func mergeEntities(base, delta jsontext.Value, key string) (jsontext.Value, error) {
+	var baseEntities, deltaEntities []OrderedObject[jsontext.Value]
+	if err := json.Unmarshal(delta, &deltaEntities); err != nil {
+		return delta, nil //nolint:nilerr // Not an array of entities, replaced as a whole
+	}
+	if len(base) > 0 {
+		if err := json.Unmarshal(base, &baseEntities); err != nil {
+			return delta, nil //nolint:nilerr // Not an array of entities, replaced as a whole
+		}
+	}
+
+	index := make(map[string]int, len(baseEntities))
+	for i, entity := range baseEntities {
+		if id, ok := entity.Get(key); ok {
+			index[string(id)] = i
+		}
+	}
+
+	deleted := make(map[int]bool)
+	for _, entity := range deltaEntities {
+		id, ok := entity.Get(key)
+		if !ok {
+			return nil, fmt.Errorf("entity without %q", key)
+		}
+		i, exists := index[string(id)]
+
+		if isDeleted(entity) {
+			if exists {
+				deleted[i] = true
+			}
+			continue
+		}
+		if !exists {
+			index[string(id)] = len(baseEntities)
+			baseEntities = append(baseEntities, entity)
+			continue
+		}
+		merged, err := mergeObject(baseEntities[i], entity)
+		if err != nil {
+			return nil, err
+		}
+		baseEntities[i] = merged
+	}
+
+	kept := make([]OrderedObject[jsontext.Value], 0, len(baseEntities)-len(deleted))
+	for i, entity := range baseEntities {
+		if !deleted[i] {
+			kept = append(kept, entity)
+		}
+	}
+
+	value, err := json.Marshal(&kept)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode merged entities: %w", err)
+	}
+	return value, nil
+}
+
+// isDeleted reports whether a delta entity is a tombstone for a deleted entity.
+func isDeleted(entity OrderedObject[jsontext.Value]) bool {
+	deleted, ok := entity.Get("deleted")
+	return ok && string(deleted) == "true"
+}
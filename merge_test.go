@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json/jsontext"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeEntities(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  string
+		delta string
+		key   string
+		want  string
+	}{
+		{
+			name:  "empty delta",
+			base:  `[{"id":"a","name":"A"},{"id":"b","name":"B"}]`,
+			delta: `[]`,
+			key:   "id",
+			want:  `[{"id":"a","name":"A"},{"id":"b","name":"B"}]`,
+		},
+		{
+			name:  "update keeps member order",
+			base:  `[{"id":"a","name":"A","amount":1},{"id":"b","name":"B","amount":2}]`,
+			delta: `[{"id":"b","amount":5}]`,
+			key:   "id",
+			want:  `[{"id":"a","name":"A","amount":1},{"id":"b","name":"B","amount":5}]`,
+		},
+		{
+			name:  "insert",
+			base:  `[{"id":"a"}]`,
+			delta: `[{"id":"c","name":"C"}]`,
+			key:   "id",
+			want:  `[{"id":"a"},{"id":"c","name":"C"}]`,
+		},
+		{
+			name:  "insert into missing array",
+			base:  ``,
+			delta: `[{"id":"a"}]`,
+			key:   "id",
+			want:  `[{"id":"a"}]`,
+		},
+		{
+			name:  "tombstone removes entity",
+			base:  `[{"id":"a","deleted":false},{"id":"b","deleted":false}]`,
+			delta: `[{"id":"a","deleted":true}]`,
+			key:   "id",
+			want:  `[{"id":"b","deleted":false}]`,
+		},
+		{
+			name:  "tombstone for unknown entity",
+			base:  `[{"id":"a"}]`,
+			delta: `[{"id":"z","deleted":true}]`,
+			key:   "id",
+			want:  `[{"id":"a"}]`,
+		},
+		{
+			name:  "nested arrays are merged",
+			base:  `[{"month":"2025-01-01","categories":[{"id":"x","budgeted":1},{"id":"y","budgeted":2}]}]`,
+			delta: `[{"month":"2025-01-01","categories":[{"id":"y","budgeted":3}]}]`,
+			key:   "month",
+			want:  `[{"month":"2025-01-01","categories":[{"id":"x","budgeted":1},{"id":"y","budgeted":3}]}]`,
+		},
+		{
+			name:  "arrays of values are replaced",
+			base:  `[1,2,3]`,
+			delta: `[4]`,
+			key:   "id",
+			want:  `[4]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeEntities(jsontext.Value(tt.base), jsontext.Value(tt.delta), tt.key)
+			if err != nil {
+				t.Fatalf("mergeEntities() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("mergeEntities() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeEntitiesWithoutKey(t *testing.T) {
+	if _, err := mergeEntities(jsontext.Value(`[{"id":"a"}]`), jsontext.Value(`[{"name":"B"}]`), "id"); err == nil {
+		t.Error("mergeEntities() with an entity without key succeeded, want an error")
+	}
+}
+
+func TestMergeExports(t *testing.T) {
+	const full = `{"data":{"budget":{"id":"b1","name":"Budget","transactions":[{"id":"t1","amount":1},{"id":"t2","amount":2}]},"server_knowledge":10}}`
+
+	tests := []struct {
+		name    string
+		deltas  []string
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "upsert and tombstone",
+			deltas: []string{`{"data":{"budget":{"id":"b1","transactions":[{"id":"t1","amount":5},{"id":"t2","deleted":true},{"id":"t3","amount":3}]},"server_knowledge":12}}`},
+			want:   `{"data":{"budget":{"id":"b1","name":"Budget","transactions":[{"id":"t1","amount":5},{"id":"t3","amount":3}]},"server_knowledge":12}}`,
+		},
+		{
+			name: "unchanged delta in a chain",
+			deltas: []string{
+				`{"data":{"budget":{"id":"b1","transactions":[{"id":"t3","amount":3}]},"server_knowledge":12}}`,
+				`{"data":{"budget":{"id":"b1","transactions":[]},"server_knowledge":12}}`,
+				`{"data":{"budget":{"id":"b1","transactions":[{"id":"t3","amount":4}]},"server_knowledge":13}}`,
+			},
+			want: `{"data":{"budget":{"id":"b1","name":"Budget","transactions":[{"id":"t1","amount":1},{"id":"t2","amount":2},{"id":"t3","amount":4}]},"server_knowledge":13}}`,
+		},
+		{
+			name:    "older delta",
+			deltas:  []string{`{"data":{"budget":{"id":"b1","transactions":[]},"server_knowledge":9}}`},
+			wantErr: "is older than",
+		},
+		{
+			name:    "other budget",
+			deltas:  []string{`{"data":{"budget":{"id":"b2","transactions":[]},"server_knowledge":11}}`},
+			wantErr: "is an export of budget",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			inputs := []string{writeTestFile(t, dir, "full.json", full)}
+			for i, delta := range tt.deltas {
+				inputs = append(inputs, writeTestFile(t, dir, fmt.Sprintf("delta-%d.json", i), delta))
+			}
+			output := filepath.Join(dir, "merged.json")
+
+			path, err := mergeExports(mergeOptions{inputs: inputs, output: output}, exportOptions{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("mergeExports() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mergeExports() error = %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("merged export = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeTestFile writes content to name in dir and returns its path.
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+)
+
+// milliunitsPerUnit is the number of milliunits, YNAB's unit for amounts, in one currency unit.
+const milliunitsPerUnit = 1000
+
+// format renders an amount in milliunits the way the budget displays it, such as
+// "-$1,234.56" or "1.234,56 €".
+func (f currencyFormat) format(milliunits int64) string {
+	number := formatDecimal(milliunits, f.DecimalDigits, cmp.Or(f.DecimalSeparator, "."), f.GroupSeparator)
+	negative := strings.HasPrefix(number, "-")
+	number = strings.TrimPrefix(number, "-")
+
+	if f.DisplaySymbol && f.CurrencySymbol != "" {
+		if f.SymbolFirst {
+			number = f.CurrencySymbol + number
+		} else {
+			number += " " + f.CurrencySymbol
+		}
+	}
+	if negative {
+		return "-" + number
+	}
+	return number
+}
+
+// decimal renders an amount in milliunits as a plain decimal number with the
+// budget's number of decimal digits, such as "-1234.56", for machine-readable formats.
+func (f currencyFormat) decimal(milliunits int64) string {
+	return formatDecimal(milliunits, f.DecimalDigits, ".", "")
+}
+
+// exact renders an amount like decimal, but with more digits when the amount has
+// fractions of the smallest currency unit, so that amounts that balance in
+// milliunits still balance when written.
+func (f currencyFormat) exact(milliunits int64) string {
+	digits := max(f.DecimalDigits, 0)
+	for scale := pow10(3 - digits); digits < 3 && milliunits%scale != 0; scale /= 10 {
+		digits++
+	}
+	return formatDecimal(milliunits, digits, ".", "")
+}
+
+// pow10 returns 10 to the power of n, or 1 for negative n.
+func pow10(n int) int64 {
+	result := int64(1)
+	for range n {
+		result *= 10
+	}
+	return result
+}
+
+// formatDecimal renders milliunits with the given number of decimal digits,
+// rounding half away from zero, and groups thousands with groupSep if not empty.
+func formatDecimal(milliunits int64, digits int, decimalSep, groupSep string) string {
+	digits = min(max(digits, 0), 3)
+	scale := pow10(3 - digits)
+
+	negative := milliunits < 0
+	abs := milliunits
+	if negative {
+		abs = -abs
+	}
+	abs = (abs + scale/2) / scale // Round to the number of digits shown
+
+	unitScale := int64(milliunitsPerUnit) / scale
+	whole := strconv.FormatInt(abs/unitScale, 10)
+	if groupSep != "" {
+		whole = groupThousands(whole, groupSep)
+	}
+
+	var b strings.Builder
+	if negative && abs != 0 {
+		b.WriteString("-")
+	}
+	b.WriteString(whole)
+	if digits > 0 {
+		frac := strconv.FormatInt(abs%unitScale, 10)
+		b.WriteString(decimalSep)
+		b.WriteString(strings.Repeat("0", digits-len(frac)))
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// groupThousands inserts sep between every group of three digits.
+func groupThousands(digits, sep string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	first := len(digits) % 3
+	if first > 0 {
+		b.WriteString(digits[:first])
+	}
+	for i := first; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package main
+
+import "testing"
+
+func TestCurrencyFormat(t *testing.T) {
+	usd := currencyFormat{ISOCode: "USD", CurrencySymbol: "$", DecimalSeparator: ".", GroupSeparator: ",",
+		DecimalDigits: 2, SymbolFirst: true, DisplaySymbol: true}
+	eur := currencyFormat{ISOCode: "EUR", CurrencySymbol: "€", DecimalSeparator: ",", GroupSeparator: ".",
+		DecimalDigits: 2, DisplaySymbol: true}
+	jpy := currencyFormat{ISOCode: "JPY", CurrencySymbol: "¥", DecimalSeparator: ".", GroupSeparator: ",",
+		DecimalDigits: 0, SymbolFirst: true, DisplaySymbol: true}
+	hidden := currencyFormat{ISOCode: "USD", CurrencySymbol: "$", DecimalDigits: 2, SymbolFirst: true}
+
+	tests := []struct {
+		name       string
+		format     currencyFormat
+		milliunits int64
+		want       string
+	}{
+		{name: "zero", format: usd, milliunits: 0, want: "$0.00"},
+		{name: "symbol first", format: usd, milliunits: 1234560, want: "$1,234.56"},
+		{name: "negative", format: usd, milliunits: -1234560, want: "-$1,234.56"},
+		{name: "cents", format: usd, milliunits: 50, want: "$0.05"},
+		{name: "rounds half away from zero", format: usd, milliunits: 1235, want: "$1.24"},
+		{name: "rounds negative half away from zero", format: usd, milliunits: -1235, want: "-$1.24"},
+		{name: "negative rounding to zero", format: usd, milliunits: -4, want: "$0.00"},
+		{name: "millions", format: usd, milliunits: 1234567890, want: "$1,234,567.89"},
+		{name: "symbol last", format: eur, milliunits: 1234560, want: "1.234,56 €"},
+		{name: "negative symbol last", format: eur, milliunits: -500, want: "-0,50 €"},
+		{name: "no decimals", format: jpy, milliunits: 1234500, want: "¥1,235"},
+		{name: "symbol hidden", format: hidden, milliunits: 1234560, want: "1234.56"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.format.format(tt.milliunits); got != tt.want {
+				t.Errorf("format(%d) = %q, want %q", tt.milliunits, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrencyDecimal(t *testing.T) {
+	tests := []struct {
+		digits     int
+		milliunits int64
+		want       string
+	}{
+		{digits: 2, milliunits: 1234560, want: "1234.56"},
+		{digits: 2, milliunits: -1234560, want: "-1234.56"},
+		{digits: 2, milliunits: 1235, want: "1.24"},
+		{digits: 2, milliunits: 1230, want: "1.23"},
+		{digits: 0, milliunits: 1500, want: "2"},
+		{digits: 0, milliunits: 2000, want: "2"},
+		{digits: 3, milliunits: 1, want: "0.001"},
+		{digits: 5, milliunits: 1, want: "0.001"},
+		{digits: -1, milliunits: 1000, want: "1"},
+	}
+	for _, tt := range tests {
+		f := currencyFormat{DecimalDigits: tt.digits}
+		if got := f.decimal(tt.milliunits); got != tt.want {
+			t.Errorf("decimal(%d) with %d digits = %q, want %q", tt.milliunits, tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestCurrencyExact(t *testing.T) {
+	tests := []struct {
+		digits     int
+		milliunits int64
+		want       string
+	}{
+		{digits: 2, milliunits: 1234560, want: "1234.56"},
+		{digits: 2, milliunits: -1234560, want: "-1234.56"},
+		{digits: 2, milliunits: 1235, want: "1.235"},
+		{digits: 2, milliunits: 1230, want: "1.23"},
+		{digits: 0, milliunits: 1500, want: "1.5"},
+		{digits: 0, milliunits: 2000, want: "2"},
+		{digits: 3, milliunits: 1, want: "0.001"},
+		{digits: 5, milliunits: 1, want: "0.001"},
+		{digits: -1, milliunits: 1000, want: "1"},
+	}
+	for _, tt := range tests {
+		f := currencyFormat{DecimalDigits: tt.digits}
+		if got := f.exact(tt.milliunits); got != tt.want {
+			t.Errorf("exact(%d) with %d digits = %q, want %q", tt.milliunits, tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestGroupThousands(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   string
+	}{
+		{digits: "0", want: "0"},
+		{digits: "123", want: "123"},
+		{digits: "1234", want: "1 234"},
+		{digits: "123456", want: "123 456"},
+		{digits: "1234567", want: "1 234 567"},
+	}
+	for _, tt := range tests {
+		if got := groupThousands(tt.digits, " "); got != tt.want {
+			t.Errorf("groupThousands(%q) = %q, want %q", tt.digits, got, tt.want)
+		}
+	}
+}
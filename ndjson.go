@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"fmt"
+	"io"
+)
+
+// ndjsonTypes names the entity type of each list in a budget. Lists not named
+// here use their member name as the type.
+var ndjsonTypes = map[string]string{
+	"accounts":                  "account",
+	"payees":                    "payee",
+	"payee_locations":           "payee_location",
+	"category_groups":           "category_group",
+	"categories":                "category",
+	"months":                    "month",
+	"transactions":              "transaction",
+	"subtransactions":           "subtransaction",
+	"scheduled_transactions":    "scheduled_transaction",
+	"scheduled_subtransactions": "scheduled_subtransaction",
+}
+
+// ndjsonLine is one line of an NDJSON export: an entity tagged with its type and budget.
+type ndjsonLine struct {
+	Type     string `json:"type"`
+	BudgetID string `json:"budget_id"`
+	Data     any    `json:"data"`
+}
+
+// ndjsonBudget is what writing a budget as NDJSON learns about it on the way:
+// enough to name and summarize the export without parsing the budget again. Of
+// the budget's lists, only the short ones are kept.
+type ndjsonBudget struct {
+	detail           budgetDetail // Without transactions or subtransactions
+	serverKnowledge  int64
+	structure        OrderedObject[string]
+	transactionCount int
+}
+
+// writeNDJSON streams the budget response of size bytes in r to w as one line per
+// entity. Each element of the budget's lists is written as soon as it is read, so
+// the budget is never held in memory; the budget's own members follow as one last
+// "budget" line. The entities are written as downloaded, so deleted ones are kept.
+// Lines get the ID the budget has in the response, or budgetID if it has none.
+func writeNDJSON(w io.Writer, r io.ReaderAt, size int64, budgetID string) (ndjsonBudget, error) {
+	lookupBudgetID := func() (string, error) {
+		id, err := readBudgetID(io.NewSectionReader(r, 0, size))
+		return cmp.Or(id, budgetID), err
+	}
+
+	dec := jsontext.NewDecoder(io.NewSectionReader(r, 0, size))
+	bw := bufio.NewWriter(w)
+	enc := jsontext.NewEncoder(bw)
+
+	// Find data.budget and data.server_knowledge, skipping everything else
+	var info ndjsonBudget
+	err := readObject(dec, func(name string) error {
+		if name != "data" {
+			return skipValue(dec)
+		}
+		return readObject(dec, func(name string) error {
+			switch name {
+			case "budget":
+				return streamBudget(dec, enc, lookupBudgetID, &info)
+			case "server_knowledge":
+				if err := json.UnmarshalDecode(dec, &info.serverKnowledge); err != nil {
+					return fmt.Errorf("failed to parse budget: %w", err)
+				}
+				return nil
+			}
+			return skipValue(dec)
+		})
+	})
+	if err != nil {
+		return ndjsonBudget{}, err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return ndjsonBudget{}, fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	return info, nil
+}
+
+// streamBudget writes each element of the lists in the budget object read from dec
+// as a line of its own, then the rest of the budget as a "budget" line. It records
+// what it reads about the budget in info. YNAB sends the budget's ID before its
+// lists, so lookupBudgetID only has to read ahead for a response that does not.
+func streamBudget(dec *jsontext.Decoder, enc *jsontext.Encoder, lookupBudgetID func() (string, error),
+	info *ndjsonBudget,
+) error {
+	var budget OrderedObject[jsontext.Value]
+	budgetID, idKnown := "", false
+	lineBudgetID := func() (string, error) {
+		if !idKnown {
+			id, err := lookupBudgetID()
+			if err != nil {
+				return "", err
+			}
+			budgetID, idKnown = id, true
+		}
+		return budgetID, nil
+	}
+	err := readObject(dec, func(name string) error {
+		if dec.PeekKind() != '[' {
+			value, err := dec.ReadValue()
+			if err != nil {
+				return fmt.Errorf("failed to parse budget: %w", err)
+			}
+			value = value.Clone()
+			budget = append(budget, ObjectMember[jsontext.Value]{Name: name, Value: value})
+
+			var v any
+			if err := json.Unmarshal(value, &v); err != nil {
+				return fmt.Errorf("failed to parse budget: %w", err)
+			}
+			if id, ok := v.(string); ok && name == "id" && !idKnown {
+				budgetID, idKnown = id, true
+			}
+			info.structure = append(info.structure, ObjectMember[string]{Name: name, Value: inspectJSONValue(v)})
+			return nil
+		}
+
+		entityType := name
+		if t, ok := ndjsonTypes[name]; ok {
+			entityType = t
+		}
+		lineID, err := lineBudgetID()
+		if err != nil {
+			return err
+		}
+		var first any
+		count := 0
+		err = readArray(dec, func() error {
+			value, err := dec.ReadValue()
+			if err != nil {
+				return fmt.Errorf("failed to parse budget: %w", err)
+			}
+			if count == 0 {
+				if err := json.Unmarshal(value, &first); err != nil {
+					return fmt.Errorf("failed to parse budget: %w", err)
+				}
+			}
+			count++
+			if err := info.add(name, value); err != nil {
+				return err
+			}
+			return writeNDJSONLine(enc, ndjsonLine{Type: entityType, BudgetID: lineID, Data: value})
+		})
+		if err != nil {
+			return err
+		}
+		info.structure = append(info.structure, ObjectMember[string]{Name: name, Value: inspectJSONArray(first, count)})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Unmarshaling merges into the struct, so the lists added above are kept
+	members, err := json.Marshal(&budget)
+	if err != nil {
+		return fmt.Errorf("failed to parse budget: %w", err)
+	}
+	if err := json.Unmarshal(members, &info.detail); err != nil {
+		return fmt.Errorf("failed to parse budget: %w", err)
+	}
+	lineID, err := lineBudgetID()
+	if err != nil {
+		return err
+	}
+	return writeNDJSONLine(enc, ndjsonLine{Type: "budget", BudgetID: lineID, Data: &budget})
+}
+
+// readBudgetID reads the ID of the budget in the budget response read from r.
+func readBudgetID(r io.Reader) (string, error) {
+	dec := jsontext.NewDecoder(r)
+	var id string
+	err := readObject(dec, func(name string) error {
+		if name != "data" {
+			return skipValue(dec)
+		}
+		return readObject(dec, func(name string) error {
+			if name != "budget" {
+				return skipValue(dec)
+			}
+			return readObject(dec, func(name string) error {
+				if name != "id" || dec.PeekKind() != '"' {
+					return skipValue(dec)
+				}
+				if err := json.UnmarshalDecode(dec, &id); err != nil {
+					return fmt.Errorf("failed to parse budget: %w", err)
+				}
+				return nil
+			})
+		})
+	})
+	return id, err
+}
+
+// add records an element of one of the budget's lists.
+func (info *ndjsonBudget) add(list string, value jsontext.Value) error {
+	var err error
+	switch list {
+	case "accounts":
+		info.detail.Accounts, err = appendDecoded(info.detail.Accounts, value)
+	case "payees":
+		info.detail.Payees, err = appendDecoded(info.detail.Payees, value)
+	case "category_groups":
+		info.detail.CategoryGroups, err = appendDecoded(info.detail.CategoryGroups, value)
+	case "categories":
+		info.detail.Categories, err = appendDecoded(info.detail.Categories, value)
+	case "transactions":
+		info.transactionCount++
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse budget %s: %w", list, err)
+	}
+	return nil
+}
+
+// appendDecoded decodes value and appends it to list.
+func appendDecoded[T any](list []T, value jsontext.Value) ([]T, error) {
+	var v T
+	if err := json.Unmarshal(value, &v); err != nil {
+		return list, err //nolint:wrapcheck // Wrapped by the caller
+	}
+	return append(list, v), nil
+}
+
+// writeNDJSONLine encodes one line of an NDJSON export.
+func writeNDJSONLine(enc *jsontext.Encoder, line ndjsonLine) error {
+	if err := json.MarshalEncode(enc, &line); err != nil {
+		return fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	return nil
+}
+
+// readObject reads a JSON object from dec, calling member with each member's name
+// while dec is positioned at its value. member must consume the value.
+func readObject(dec *jsontext.Decoder, member func(name string) error) error {
+	if k := dec.PeekKind(); k != '{' {
+		return fmt.Errorf("failed to parse budget: expected object start, but encountered %v", k)
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("failed to parse budget: %w", err)
+	}
+	for dec.PeekKind() != '}' {
+		tok, err := dec.ReadToken()
+		if err != nil {
+			return fmt.Errorf("failed to parse budget: %w", err)
+		}
+		if err := member(tok.String()); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("failed to parse budget: %w", err)
+	}
+	return nil
+}
+
+// readArray reads a JSON array from dec, calling element while dec is positioned
+// at each element. element must consume the value.
+func readArray(dec *jsontext.Decoder, element func() error) error {
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("failed to parse budget: %w", err)
+	}
+	for dec.PeekKind() != ']' {
+		if err := element(); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("failed to parse budget: %w", err)
+	}
+	return nil
+}
+
+// skipValue reads past the next value of dec without decoding it.
+func skipValue(dec *jsontext.Decoder) error {
+	if err := dec.SkipValue(); err != nil {
+		return fmt.Errorf("failed to parse budget: %w", err)
+	}
+	return nil
+}
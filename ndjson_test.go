@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ndjsonTestBudget is a budget response with the budget's ID before its lists, as
+// YNAB sends it.
+const ndjsonTestBudget = `{"data": {"budget": {
+	"id": "b1", "name": "Test Budget", "first_month": "2025-01-01", "last_month": "2025-02-01",
+	"currency_format": {"iso_code": "USD", "currency_symbol": "$"},
+	"accounts": [{"id": "a-chk", "name": "Checking", "closed": false}, {"id": "a-old", "name": "Old", "closed": true}],
+	"payees": [{"id": "p-grocer", "name": "Grocer"}],
+	"category_groups": [],
+	"categories": [{"id": "c-rent", "name": "Rent", "hidden": true}],
+	"transactions": [{"id": "t-1", "amount": -45670}, {"id": "t-gone", "amount": -1000, "deleted": true}],
+	"tags": ["a"]
+}, "server_knowledge": 42}}`
+
+type ndjsonTestLine struct {
+	Type     string `json:"type"`
+	BudgetID string `json:"budget_id"`
+	Data     any    `json:"data"`
+}
+
+func writeTestNDJSON(t *testing.T, response, budgetID string) ([]ndjsonTestLine, ndjsonBudget) {
+	t.Helper()
+	var b strings.Builder
+	info, err := writeNDJSON(&b, strings.NewReader(response), int64(len(response)), budgetID)
+	if err != nil {
+		t.Fatalf("writeNDJSON() error = %v", err)
+	}
+	var lines []ndjsonTestLine
+	for line := range strings.Lines(b.String()) {
+		var l ndjsonTestLine
+		if err := json.Unmarshal([]byte(line), &l); err != nil {
+			t.Fatalf("line %q is not JSON: %v", line, err)
+		}
+		lines = append(lines, l)
+	}
+	return lines, info
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	lines, info := writeTestNDJSON(t, ndjsonTestBudget, "last-used")
+
+	var types []string
+	for _, l := range lines {
+		types = append(types, l.Type)
+		if l.BudgetID != "b1" {
+			t.Errorf("%s line has budget_id %q, want b1", l.Type, l.BudgetID)
+		}
+	}
+	wantTypes := []string{"account", "account", "payee", "category", "transaction", "transaction", "tags", "budget"}
+	if !slices.Equal(types, wantTypes) {
+		t.Fatalf("line types = %q, want %q", types, wantTypes)
+	}
+	gone, _ := lines[5].Data.(map[string]any)
+	if deleted, _ := gone["deleted"].(bool); gone["id"] != "t-gone" || !deleted {
+		t.Errorf("deleted transaction line = %v, want t-gone kept as deleted", lines[5].Data)
+	}
+	budget, _ := lines[len(lines)-1].Data.(map[string]any)
+	if budget["name"] != "Test Budget" || budget["accounts"] != nil {
+		t.Errorf("budget line = %v, want the budget's members without its lists", budget)
+	}
+
+	if info.serverKnowledge != 42 {
+		t.Errorf("server knowledge = %d, want 42", info.serverKnowledge)
+	}
+	summary := createBudgetSummary(info.detail)
+	summary.TransactionCount = info.transactionCount
+	want := budgetSummary{Name: "Test Budget", Currency: "USD ($)", FirstMonth: "2025-01-01", LastMonth: "2025-02-01",
+		AccountCount: 1, ClosedAccountCount: 1, TransactionCount: 2, HiddenCategoryCount: 1, PayeeCount: 1}
+	if summary != want {
+		t.Errorf("summary = %+v, want %+v", summary, want)
+	}
+
+	// The structure matches what parsing the whole budget describes
+	structure, err := describeBudget(strings.NewReader(ndjsonTestBudget))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(info.structure, structure) {
+		t.Errorf("structure = %v, want %v", info.structure, structure)
+	}
+}
+
+func TestWriteNDJSONBudgetID(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{
+			name:     "id after the lists",
+			response: `{"data": {"budget": {"accounts": [{"id": "a-chk"}], "name": "Test", "id": "b1"}}}`,
+			want:     "b1",
+		},
+		{
+			name:     "no id",
+			response: `{"data": {"budget": {"accounts": [{"id": "a-chk"}], "name": "Test"}}}`,
+			want:     "requested",
+		},
+		{
+			name:     "no lists",
+			response: `{"data": {"budget": {"name": "Test"}, "server_knowledge": 1}}`,
+			want:     "requested",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, _ := writeTestNDJSON(t, tt.response, "requested")
+			for _, l := range lines {
+				if l.BudgetID != tt.want {
+					t.Errorf("%s line has budget_id %q, want %q", l.Type, l.BudgetID, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteNDJSONMalformed(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+	}{
+		{name: "not an object", response: `[]`},
+		{name: "budget not an object", response: `{"data": {"budget": []}}`},
+		{name: "truncated list", response: `{"data": {"budget": {"id": "b1", "transactions": [{"id": "t-1"}, {"id"`},
+		{name: "invalid value", response: `{"data": {"budget": {"id": "b1", "name": nope}}}`},
+		{name: "invalid account", response: `{"data": {"budget": {"id": "b1", "accounts": [{"closed": "yes"}]}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			_, err := writeNDJSON(&b, strings.NewReader(tt.response), int64(len(tt.response)), "b1")
+			if err == nil || !strings.Contains(err.Error(), "failed to parse budget") {
+				t.Errorf("writeNDJSON() error = %v, want a parse error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ofxHeader starts an OFX 2.2 file.
+const ofxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n" +
+	`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+
+// Limits and formats from the OFX specification.
+const (
+	ofxDateFormat     = "20060102150405"
+	ofxAccountIDLimit = 22 // ACCTID is at most 22 characters
+	ofxNameLimit      = 32 // NAME is at most 32 characters
+	ofxBankID         = "YNAB"
+)
+
+type ofxDocument struct {
+	XMLName    xml.Name              `xml:"OFX"`
+	SignOn     ofxSignOn             `xml:"SIGNONMSGSRSV1>SONRS"`
+	Bank       *ofxStatementResponse `xml:"BANKMSGSRSV1>STMTTRNRS,omitempty"`
+	CreditCard *ofxStatementResponse `xml:"CREDITCARDMSGSRSV1>CCSTMTTRNRS,omitempty"`
+}
+
+type ofxSignOn struct {
+	Status   ofxStatus `xml:"STATUS"`
+	Server   string    `xml:"DTSERVER"`
+	Language string    `xml:"LANGUAGE"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxStatementResponse struct {
+	TransactionUID string        `xml:"TRNUID"`
+	Status         ofxStatus     `xml:"STATUS"`
+	Bank           *ofxStatement `xml:"STMTRS,omitempty"`
+	CreditCard     *ofxStatement `xml:"CCSTMTRS,omitempty"`
+}
+
+type ofxStatement struct {
+	Currency          string             `xml:"CURDEF"`
+	BankAccount       *ofxAccount        `xml:"BANKACCTFROM,omitempty"`
+	CreditCardAccount *ofxAccount        `xml:"CCACCTFROM,omitempty"`
+	Transactions      ofxTransactionList `xml:"BANKTRANLIST"`
+	LedgerBalance     ofxBalance         `xml:"LEDGERBAL"`
+	AvailableBalance  ofxBalance         `xml:"AVAILBAL"`
+}
+
+type ofxAccount struct {
+	BankID      string `xml:"BANKID,omitempty"`
+	AccountID   string `xml:"ACCTID"`
+	AccountType string `xml:"ACCTTYPE,omitempty"`
+}
+
+type ofxTransactionList struct {
+	Start        string           `xml:"DTSTART"`
+	End          string           `xml:"DTEND"`
+	Transactions []ofxTransaction `xml:"STMTTRN"`
+}
+
+type ofxTransaction struct {
+	Type   string `xml:"TRNTYPE"`
+	Posted string `xml:"DTPOSTED"`
+	Amount string `xml:"TRNAMT"`
+	FITID  string `xml:"FITID"`
+	Name   string `xml:"NAME,omitempty"`
+	Memo   string `xml:"MEMO,omitempty"`
+}
+
+type ofxBalance struct {
+	Amount string `xml:"BALAMT"`
+	AsOf   string `xml:"DTASOF"`
+}
+
+// writeOFX writes the transactions of one account to w as an OFX 2.2 statement.
+// Credit cards get a credit card statement and other accounts a bank statement.
+// Split transactions are written whole, as a bank would show them.
+func writeOFX(w io.Writer, budget budgetDetail, acc account, now time.Time) error {
+	idx := newBudgetIndex(budget)
+	txns := accountTransactions(budget, acc.ID)
+
+	asOf := now.UTC().Format(ofxDateFormat)
+	list := ofxTransactionList{Start: asOf, End: asOf}
+	if len(txns) > 0 {
+		list.Start, list.End = ofxDate(txns[0].Date), ofxDate(txns[len(txns)-1].Date)
+	}
+	for _, t := range txns {
+		trnType := "CREDIT"
+		switch {
+		case t.TransferAccountID != "":
+			trnType = "XFER"
+		case t.Amount < 0:
+			trnType = "DEBIT"
+		}
+		list.Transactions = append(list.Transactions, ofxTransaction{
+			Type:   trnType,
+			Posted: ofxDate(t.Date),
+			Amount: budget.CurrencyFormat.decimal(t.Amount),
+			FITID:  t.ID,
+			Name:   truncateRunes(idx.payees[t.PayeeID], ofxNameLimit),
+			Memo:   t.Memo,
+		})
+	}
+
+	// The ledger balance includes uncleared transactions, so it matches the sum of
+	// the transactions; the cleared balance is what the bank has made available
+	statement := &ofxStatement{
+		Currency:         budget.CurrencyFormat.ISOCode,
+		Transactions:     list,
+		LedgerBalance:    ofxBalance{Amount: budget.CurrencyFormat.decimal(acc.Balance), AsOf: asOf},
+		AvailableBalance: ofxBalance{Amount: budget.CurrencyFormat.decimal(acc.ClearedBalance), AsOf: asOf},
+	}
+	ok := ofxStatus{Code: 0, Severity: "INFO"}
+	response := &ofxStatementResponse{TransactionUID: "0", Status: ok}
+	doc := ofxDocument{SignOn: ofxSignOn{Status: ok, Server: asOf, Language: "ENG"}}
+
+	accountID := truncateRunes(strings.ReplaceAll(acc.ID, "-", ""), ofxAccountIDLimit)
+	if acc.Type == accountTypeCreditCard {
+		statement.CreditCardAccount = &ofxAccount{AccountID: accountID}
+		response.CreditCard = statement
+		doc.CreditCard = response
+	} else {
+		statement.BankAccount = &ofxAccount{BankID: ofxBankID, AccountID: accountID, AccountType: ofxAccountType(acc.Type)}
+		response.Bank = statement
+		doc.Bank = response
+	}
+
+	if _, err := io.WriteString(w, ofxHeader); err != nil {
+		return fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	return nil
+}
+
+// ofxAccountType maps a YNAB account type to an OFX bank account type. Accounts
+// without an OFX equivalent, such as cash or loans, are written as checking.
+func ofxAccountType(accountType string) string {
+	switch accountType {
+	case accountTypeSavings:
+		return "SAVINGS"
+	case accountTypeLineOfCredit:
+		return "CREDITLINE"
+	}
+	return "CHECKING"
+}
+
+// ofxDate converts a YYYY-MM-DD date to the OFX YYYYMMDD format.
+func ofxDate(date string) string {
+	return strings.ReplaceAll(date, "-", "")
+}
+
+// truncateRunes shortens s to at most n characters.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
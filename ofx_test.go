@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/xml"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteOFX(t *testing.T) {
+	budget := testBudget()
+	now := time.Date(2025, 2, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		account     account
+		creditCard  bool
+		wantAccount ofxAccount
+		wantList    ofxTransactionList
+		wantLedger  string
+		wantAvail   string
+	}{
+		{
+			name:        "bank statement",
+			account:     budget.Accounts[0],
+			wantAccount: ofxAccount{BankID: ofxBankID, AccountID: "achk", AccountType: "CHECKING"},
+			wantList: ofxTransactionList{Start: "20250103", End: "20250112", Transactions: []ofxTransaction{
+				{Type: "DEBIT", Posted: "20250103", Amount: "-45.67", FITID: "t-shop", Name: "Grocer", Memo: "Weekly shop"},
+				{Type: "CREDIT", Posted: "20250105", Amount: "2000.00", FITID: "t-pay", Name: "Employer"},
+				{Type: "DEBIT", Posted: "20250110", Amount: "-120.00", FITID: "t-split", Name: "Grocer", Memo: "Big shop"},
+				{Type: "XFER", Posted: "20250112", Amount: "-500.00", FITID: "t-to-sav", Name: "Transfer : Savings"},
+			}},
+			wantLedger: "1321.99",
+			wantAvail:  "1334.33",
+		},
+		{
+			name:        "credit card statement",
+			account:     budget.Accounts[1],
+			creditCard:  true,
+			wantAccount: ofxAccount{AccountID: "avisa"},
+			wantList: ofxTransactionList{Start: "20250108", End: "20250108", Transactions: []ofxTransaction{
+				{Type: "DEBIT", Posted: "20250108", Amount: "-12.34", FITID: "t-card", Name: "Grocer"},
+			}},
+			wantLedger: "-12.34",
+			wantAvail:  "0.00",
+		},
+		{
+			name:        "no transactions",
+			account:     account{ID: "a-empty", Name: "Empty", Type: accountTypeSavings},
+			wantAccount: ofxAccount{BankID: ofxBankID, AccountID: "aempty", AccountType: "SAVINGS"},
+			wantList:    ofxTransactionList{Start: "20250201123000", End: "20250201123000"},
+			wantLedger:  "0.00",
+			wantAvail:   "0.00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := writeOFX(&b, budget, tt.account, now); err != nil {
+				t.Fatalf("writeOFX() error = %v", err)
+			}
+			if !strings.HasPrefix(b.String(), ofxHeader) {
+				t.Errorf("writeOFX() does not start with the OFX header:\n%s", b.String())
+			}
+
+			var doc ofxDocument
+			if err := xml.Unmarshal([]byte(b.String()), &doc); err != nil {
+				t.Fatalf("failed to parse OFX: %v", err)
+			}
+			if doc.SignOn.Server != "20250201123000" {
+				t.Errorf("DTSERVER = %q, want %q", doc.SignOn.Server, "20250201123000")
+			}
+
+			resp, other := doc.Bank, doc.CreditCard
+			if tt.creditCard {
+				resp, other = other, resp
+			}
+			if resp == nil || other != nil {
+				t.Fatalf("wrong statement response, bank = %v, credit card = %v", doc.Bank, doc.CreditCard)
+			}
+			stmt := resp.Bank
+			if tt.creditCard {
+				stmt = resp.CreditCard
+			}
+			if stmt == nil {
+				t.Fatal("statement is missing")
+			}
+			acct := stmt.BankAccount
+			if tt.creditCard {
+				acct = stmt.CreditCardAccount
+			}
+			if acct == nil || *acct != tt.wantAccount {
+				t.Errorf("account = %+v, want %+v", acct, tt.wantAccount)
+			}
+			if stmt.Currency != "USD" {
+				t.Errorf("CURDEF = %q, want USD", stmt.Currency)
+			}
+
+			list := stmt.Transactions
+			if list.Start != tt.wantList.Start || list.End != tt.wantList.End {
+				t.Errorf("range = %s-%s, want %s-%s", list.Start, list.End, tt.wantList.Start, tt.wantList.End)
+			}
+			if !slices.Equal(list.Transactions, tt.wantList.Transactions) {
+				t.Errorf("transactions = %+v, want %+v", list.Transactions, tt.wantList.Transactions)
+			}
+			if stmt.LedgerBalance.Amount != tt.wantLedger || stmt.AvailableBalance.Amount != tt.wantAvail {
+				t.Errorf("balances = %s/%s, want %s/%s", stmt.LedgerBalance.Amount, stmt.AvailableBalance.Amount,
+					tt.wantLedger, tt.wantAvail)
+			}
+		})
+	}
+}
+
+func TestOFXAccountType(t *testing.T) {
+	tests := []struct {
+		accountType string
+		want        string
+	}{
+		{accountType: accountTypeChecking, want: "CHECKING"},
+		{accountType: accountTypeSavings, want: "SAVINGS"},
+		{accountType: accountTypeLineOfCredit, want: "CREDITLINE"},
+		{accountType: accountTypeCash, want: "CHECKING"},
+		{accountType: "", want: "CHECKING"},
+	}
+	for _, tt := range tests {
+		if got := ofxAccountType(tt.accountType); got != tt.want {
+			t.Errorf("ofxAccountType(%q) = %q, want %q", tt.accountType, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{s: "Grocer", n: 32, want: "Grocer"},
+		{s: "Grocer", n: 6, want: "Grocer"},
+		{s: "Grocer", n: 3, want: "Gro"},
+		{s: "Café Crème", n: 4, want: "Café"},
+		{s: "日本語テキスト", n: 3, want: "日本語"},
+		{s: "", n: 3, want: ""},
+	}
+	for _, tt := range tests {
+		if got := truncateRunes(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncateRunes(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
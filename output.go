@@ -0,0 +1,394 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultFilenameTemplate reproduces the original ynab-export-<name>-<timestamp> naming.
+const defaultFilenameTemplate = "ynab-export-{budget_name}-{date}-{time}"
+
+// filenamePlaceholders lists the placeholders accepted in filename templates.
+var filenamePlaceholders = []string{"budget_name", "budget_id", "date", "time", "server_knowledge", "currency"}
+
+// placeholderPattern matches a {placeholder} in a filename template.
+var placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// stdoutTarget is the --output value that writes the export to standard output.
+const stdoutTarget = "-"
+
+var errInvalidTemplate = errors.New("invalid filename template")
+
+// exportOptions controls where and how exports are written.
+type exportOptions struct {
+	dir              string
+	filenameTemplate string
+	output           string // Explicit output file, or "-" for stdout; overrides dir and template
+	format           exportFormat
+	journal          journalConfig // Account hierarchy for the journal formats
+	compress         compression
+	encrypt          encryption
+	overwrite        overwritePolicy
+	sinceLast        bool // Only export changes since the budget's last export
+}
+
+// toStdout reports whether the export is written to standard output.
+func (o exportOptions) toStdout() bool {
+	return o.output == stdoutTarget
+}
+
+// resolve fills in settings not given on the command line from the environment,
+// then the config file, then the defaults, and validates the result.
+func (o *exportOptions) resolve(cfg config) error {
+	o.dir = cmp.Or(o.dir, os.Getenv("YNAB_EXPORT_OUTPUT_DIR"), cfg.OutputDir)
+	if o.dir == "" {
+		// Default to the Downloads directory (cross-platform)
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to determine home directory: %w", err)
+		}
+		o.dir = filepath.Join(homeDir, "Downloads")
+	}
+	dir, err := expandHome(o.dir)
+	if err != nil {
+		return err
+	}
+	o.dir = dir
+
+	format, err := parseFormat(cmp.Or(string(o.format), os.Getenv("YNAB_EXPORT_FORMAT"), cfg.Format))
+	if err != nil {
+		return err
+	}
+	o.format = format
+	o.journal = cfg.Journal
+	if o.sinceLast && o.format != formatJSON && o.format != formatNDJSON {
+		// A delta only holds what changed, which is not enough to convert
+		return fmt.Errorf("--since-last only works with the json and ndjson formats, not %s", o.format)
+	}
+	if o.toStdout() && o.format.severalFiles() {
+		return fmt.Errorf("the %s format writes several files, so it cannot be written to stdout", o.format)
+	}
+	if o.format == formatSQLite && (o.toStdout() || o.compress != compressionNone) {
+		return errors.New("the sqlite format updates a database file in place, so it cannot be written to stdout or compressed")
+	}
+
+	o.filenameTemplate = cmp.Or(o.filenameTemplate, os.Getenv("YNAB_EXPORT_FILENAME_TEMPLATE"),
+		cfg.FilenameTemplate, defaultFilenameTemplate)
+	return validateFilenameTemplate(o.filenameTemplate)
+}
+
+// expandHome replaces a leading ~ with the user's home directory, since paths from
+// the config file or environment are not expanded by a shell.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to expand %s: %w", path, err)
+	}
+	return filepath.Join(homeDir, path[1:]), nil
+}
+
+// validateFilenameTemplate checks that a template only uses known placeholders and
+// always renders to a plain file name inside the output directory.
+func validateFilenameTemplate(tmpl string) error {
+	if strings.TrimSpace(tmpl) == "" {
+		return fmt.Errorf("%w: template is empty", errInvalidTemplate)
+	}
+
+	for _, match := range placeholderPattern.FindAllStringSubmatch(tmpl, -1) {
+		if !slices.Contains(filenamePlaceholders, match[1]) {
+			return fmt.Errorf("%w: unknown placeholder %s (available: {%s})",
+				errInvalidTemplate, match[0], strings.Join(filenamePlaceholders, "}, {"))
+		}
+	}
+
+	// Anything left after removing placeholders is literal text
+	literal := placeholderPattern.ReplaceAllString(tmpl, "")
+	if strings.ContainsAny(literal, "{}") {
+		return fmt.Errorf("%w: unbalanced braces in %q", errInvalidTemplate, tmpl)
+	}
+	if strings.ContainsAny(literal, `/\:`) {
+		return fmt.Errorf("%w: %q must be a file name, not a path", errInvalidTemplate, tmpl)
+	}
+
+	sampleValues := make(map[string]string, len(filenamePlaceholders))
+	for _, p := range filenamePlaceholders {
+		sampleValues[p] = p
+	}
+	sample := renderFilename(tmpl, sampleValues)
+	if !filepath.IsLocal(sample) || strings.Contains(sample, "..") {
+		return fmt.Errorf("%w: %q would write outside the output directory", errInvalidTemplate, tmpl)
+	}
+
+	return nil
+}
+
+// renderFilename substitutes placeholder values into a filename template.
+// Placeholders without a value render as empty strings.
+func renderFilename(tmpl string, values map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(tmpl, func(match string) string {
+		return values[match[1:len(match)-1]]
+	})
+}
+
+// exportFilename builds the file name (without extension) for an exported budget.
+func (o exportOptions) exportFilename(budgetID, budgetName string, detail budgetDetail,
+	serverKnowledge int64, now time.Time,
+) (string, error) {
+	id := cmp.Or(detail.ID, budgetID)
+	name := renderFilename(o.filenameTemplate, map[string]string{
+		"budget_name":      slugify(budgetName, id),
+		"budget_id":        id,
+		"date":             now.Format("20060102"),
+		"time":             now.Format("150405"),
+		"server_knowledge": strconv.FormatInt(serverKnowledge, 10),
+		"currency":         detail.CurrencyFormat.ISOCode,
+	})
+
+	// Substituted values must not be able to escape the directory either
+	if !filepath.IsLocal(name) || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("%w: %q is not a valid file name", errInvalidTemplate, name)
+	}
+	return name, nil
+}
+
+// outputPath returns the path to write an exported budget to, creating its
+// directory if needed. Delta exports get a -delta suffix, even with --output, so
+// a delta never takes the place of a full export.
+func (o exportOptions) outputPath(budgetID, budgetName string, detail budgetDetail, serverKnowledge int64,
+	delta bool,
+) (string, error) {
+	if o.output != "" {
+		if err := os.MkdirAll(filepath.Dir(o.output), 0o750); err != nil {
+			return "", fmt.Errorf("%w: %w", errWriteExport, err)
+		}
+		if delta {
+			return partPath(o.output, "delta"), nil
+		}
+		return o.output, nil
+	}
+
+	dir, err := o.ensureDir()
+	if err != nil {
+		return "", err
+	}
+
+	// Create filename from the template
+	filename, err := o.exportFilename(budgetID, budgetName, detail, serverKnowledge, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if delta {
+		filename += "-delta"
+	}
+	return filepath.Join(dir, filename+o.format.ext()+o.compress.ext()+o.encrypt.ext()), nil
+}
+
+// downloadDir returns the directory to download budgets to before they are written
+// to their export file: the export's own directory, so the download can simply
+// be moved into place, or the temporary directory when writing to stdout.
+func (o exportOptions) downloadDir() (string, error) {
+	switch {
+	case o.toStdout():
+		return os.TempDir(), nil
+	case o.output != "":
+		dir := filepath.Dir(o.output)
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return "", fmt.Errorf("%w: %w", errWriteExport, err)
+		}
+		return dir, nil
+	}
+	return o.ensureDir()
+}
+
+// exportSize is the size of what an export wrote, summed over its files.
+type exportSize struct {
+	raw        int64 // Before compression
+	compressed int64 // After compression, before encryption
+}
+
+// writeDownload writes a budget downloaded to raw as the export at path, converting
+// the parsed budget if the export is not JSON or storing it in a database. NDJSON
+// is converted as the download is parsed, so raw already holds its lines. Unless
+// it is written to stdout, compressed or encrypted, a JSON or NDJSON download is
+// moved into place as is. It returns the final paths, several for per-account formats,
+// and the size of what was written.
+func (o exportOptions) writeDownload(ctx context.Context, raw *atomicFile, path string, budget budgetDetail,
+) ([]string, exportSize, error) {
+	switch {
+	case o.format == formatSQLite:
+		finalPath, err := o.writeDatabase(ctx, raw, path)
+		if err != nil {
+			return nil, exportSize{}, err
+		}
+		info, err := os.Stat(finalPath)
+		if err != nil {
+			return nil, exportSize{}, fmt.Errorf("%w: %w", errWriteExport, err)
+		}
+		return []string{finalPath}, exportSize{raw: info.Size()}, nil
+	case o.format.perAccount():
+		return o.writeParts(path, o.accountParts(budget))
+	case o.format == formatParquet:
+		b, err := readParquetBudget(raw)
+		if err != nil {
+			return nil, exportSize{}, err
+		}
+		return o.writeParts(path, b.parts())
+	case o.format != formatJSON && o.format != formatNDJSON:
+		finalPath, size, err := o.writeConverted(path, func(w io.Writer) error {
+			return o.format.write(w, budget, o.journal)
+		})
+		return []string{finalPath}, size, err
+	}
+
+	if !o.toStdout() && o.compress == compressionNone && !o.encrypt.enabled() {
+		info, err := raw.Stat()
+		if err != nil {
+			return nil, exportSize{}, fmt.Errorf("failed to read budget: %w", err)
+		}
+		raw.target = path
+		finalPath, err := raw.Commit(o.overwrite)
+		return []string{finalPath}, exportSize{raw: info.Size()}, err
+	}
+
+	if _, err := raw.Seek(0, io.SeekStart); err != nil {
+		return nil, exportSize{}, fmt.Errorf("failed to read budget: %w", err)
+	}
+	finalPath, size, err := o.writeExport(path, raw)
+	return []string{finalPath}, size, err
+}
+
+// exportPart is one of the files of a format that writes several files per budget.
+type exportPart struct {
+	name  string // For messages
+	slug  string // Added to the export's file name
+	write func(io.Writer) error
+}
+
+// accountParts returns a file for each account of the budget. Accounts with the
+// same name still get files of their own.
+func (o exportOptions) accountParts(budget budgetDetail) []exportPart {
+	var parts []exportPart
+	used := make(map[string]bool)
+	for _, acc := range budget.Accounts {
+		if acc.Deleted {
+			continue
+		}
+
+		slug := slugify(acc.Name, acc.ID)
+		for n := 2; used[slug]; n++ {
+			slug = fmt.Sprintf("%s-%d", slugify(acc.Name, acc.ID), n)
+		}
+		used[slug] = true
+
+		parts = append(parts, exportPart{name: "account " + acc.Name, slug: slug, write: func(w io.Writer) error {
+			return o.format.writeAccount(w, budget, acc)
+		}})
+	}
+	return parts
+}
+
+// writeParts writes each part to its own file, named after path with the part's
+// slug added, and returns the final paths and the total size.
+func (o exportOptions) writeParts(path string, parts []exportPart) ([]string, exportSize, error) {
+	var paths []string
+	var total exportSize
+	for _, part := range parts {
+		finalPath, size, err := o.writeConverted(partPath(path, part.slug), part.write)
+		if err != nil {
+			return paths, total, fmt.Errorf("failed to export %s: %w", part.name, err)
+		}
+		paths = append(paths, finalPath)
+		total.raw += size.raw
+		total.compressed += size.compressed
+	}
+	return paths, total, nil
+}
+
+// writeConverted writes what convert produces as the export at path. The conversion
+// runs while the export is written, so the converted budget is never held in memory.
+func (o exportOptions) writeConverted(path string, convert func(io.Writer) error) (string, exportSize, error) {
+	pr, pw := io.Pipe()
+	defer pr.Close() //nolint:errcheck // Stops the conversion if writing fails
+	go func() {
+		pw.CloseWithError(convert(pw))
+	}()
+	return o.writeExport(path, pr)
+}
+
+// partPath inserts the slug of one of several files before the extensions of an
+// export path, e.g. export.ofx.gz becomes export-checking.ofx.gz.
+func partPath(path, slug string) string {
+	dir, base := filepath.Split(path)
+	name, ext := base, ""
+	if i := strings.IndexByte(base, '.'); i > 0 {
+		name, ext = base[:i], base[i:]
+	}
+	return dir + name + "-" + slug + ext
+}
+
+// writeExport writes the exported data to stdout or atomically to path, compressing
+// and encrypting it if requested. It returns the final path and the size written.
+func (o exportOptions) writeExport(path string, data io.Reader) (string, exportSize, error) {
+	if o.toStdout() {
+		size, err := o.encode(os.Stdout, data)
+		return stdoutTarget, size, err
+	}
+
+	f, err := createAtomic(path)
+	if err != nil {
+		return "", exportSize{}, err
+	}
+	size, err := o.encode(f, data)
+	if err != nil {
+		f.Abort()
+		return "", exportSize{}, err
+	}
+	finalPath, err := f.Commit(o.overwrite)
+	return finalPath, size, err
+}
+
+// encode copies data to w, compressing and then encrypting it if requested, and
+// returns the size of data and its compressed size (before encryption).
+func (o exportOptions) encode(w io.Writer, data io.Reader) (exportSize, error) {
+	ew, err := o.encrypt.newWriter(w)
+	if err != nil {
+		return exportSize{}, err
+	}
+	cw := &countingWriter{w: ew}
+	zw, err := o.compress.newWriter(cw)
+	if err != nil {
+		return exportSize{}, err
+	}
+	n, err := io.Copy(zw, data)
+	if err != nil {
+		return exportSize{}, fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	if err := zw.Close(); err != nil {
+		return exportSize{}, fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	if err := ew.Close(); err != nil {
+		return exportSize{}, fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	return exportSize{raw: n, compressed: cw.n}, nil
+}
+
+// ensureDir creates the output directory if needed and returns it.
+func (o exportOptions) ensureDir() (string, error) {
+	if err := os.MkdirAll(o.dir, 0o750); err != nil {
+		return "", fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	return o.dir, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidateFilenameTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		wantErr bool
+	}{
+		{name: "default", tmpl: defaultFilenameTemplate},
+		{name: "every placeholder", tmpl: "{budget_name}-{budget_id}-{date}-{time}-{server_knowledge}-{currency}"},
+		{name: "literal only", tmpl: "ynab"},
+		{name: "empty", tmpl: "  ", wantErr: true},
+		{name: "unknown placeholder", tmpl: "{budget}-{date}", wantErr: true},
+		{name: "unbalanced open brace", tmpl: "{budget_name", wantErr: true},
+		{name: "unbalanced close brace", tmpl: "budget_name}", wantErr: true},
+		{name: "slash", tmpl: "exports/{budget_name}", wantErr: true},
+		{name: "backslash", tmpl: `exports\{budget_name}`, wantErr: true},
+		{name: "colon", tmpl: "C:{budget_name}", wantErr: true},
+		{name: "parent directory", tmpl: "..{budget_name}", wantErr: true},
+		{name: "dot dot", tmpl: "..", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFilenameTemplate(tt.tmpl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateFilenameTemplate(%q) error = %v, wantErr %v", tt.tmpl, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errInvalidTemplate) {
+				t.Errorf("validateFilenameTemplate(%q) error = %v, want errInvalidTemplate", tt.tmpl, err)
+			}
+		})
+	}
+}
+
+func TestExportFilename(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	detail := budgetDetail{ID: "b1", CurrencyFormat: currencyFormat{ISOCode: "EUR"}}
+
+	tests := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{name: "default", tmpl: defaultFilenameTemplate, want: "ynab-export-my-budget-20250102-030405"},
+		{name: "id and currency", tmpl: "{budget_id}-{currency}", want: "b1-EUR"},
+		{name: "server knowledge", tmpl: "{budget_name}@{server_knowledge}", want: "my-budget@42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := exportOptions{filenameTemplate: tt.tmpl}
+			got, err := o.exportFilename("last-used", "My Budget", detail, 42, now)
+			if err != nil {
+				t.Fatalf("exportFilename() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("exportFilename() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputPathDelta(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		output string
+		delta  bool
+		want   string
+	}{
+		{name: "explicit full export", output: filepath.Join(dir, "backup.json"), want: filepath.Join(dir, "backup.json")},
+		{name: "explicit delta", output: filepath.Join(dir, "backup.json"), delta: true, want: filepath.Join(dir, "backup-delta.json")},
+		{name: "explicit compressed delta", output: filepath.Join(dir, "backup.json.zst"), delta: true, want: filepath.Join(dir, "backup-delta.json.zst")},
+		{name: "templated delta", delta: true, want: filepath.Join(dir, "b1-delta.json")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := exportOptions{dir: dir, filenameTemplate: "{budget_id}", output: tt.output, format: formatJSON}
+			got, err := o.outputPath("b1", "My Budget", budgetDetail{}, 1, tt.delta)
+			if err != nil {
+				t.Fatalf("outputPath() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("outputPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+)
+
+// parquetDocument is a budget download as read for the Parquet files.
+type parquetDocument struct {
+	Data struct {
+		Budget parquetBudget `json:"budget"`
+	} `json:"data"`
+}
+
+// parquetBudget holds the entities of a budget, each written to its own file.
+// Every row has the budget_id of its budget, so the files of several budgets can
+// be queried together. Columns of milliunits come with a DECIMAL(18,3) twin named
+// <column>_decimal, which holds the same value in currency units, so no query has
+// to divide by 1000. Dates are DATE and timestamps TIMESTAMP columns, and fields
+// YNAB may leave null are optional columns.
+type parquetBudget struct {
+	ID                       string                           `json:"id"`
+	Name                     string                           `json:"name"`
+	LastModifiedOn           *time.Time                       `json:"last_modified_on"`
+	FirstMonth               *parquetDate                     `json:"first_month"`
+	LastMonth                *parquetDate                     `json:"last_month"`
+	DateFormat               *parquetDateFormat               `json:"date_format"`
+	CurrencyFormat           *parquetCurrencyFormat           `json:"currency_format"`
+	Accounts                 []parquetAccount                 `json:"accounts"`
+	CategoryGroups           []parquetCategoryGroup           `json:"category_groups"`
+	Categories               []parquetCategory                `json:"categories"`
+	Payees                   []parquetPayee                   `json:"payees"`
+	PayeeLocations           []parquetPayeeLocation           `json:"payee_locations"`
+	Months                   []parquetMonth                   `json:"months"`
+	Transactions             []parquetTransaction             `json:"transactions"`
+	Subtransactions          []parquetSubtransaction          `json:"subtransactions"`
+	ScheduledTransactions    []parquetScheduledTransaction    `json:"scheduled_transactions"`
+	ScheduledSubtransactions []parquetScheduledSubtransaction `json:"scheduled_subtransactions"`
+}
+
+type parquetDateFormat struct {
+	Format *string `json:"format"`
+}
+
+type parquetCurrencyFormat struct {
+	ISOCode          *string `json:"iso_code"`
+	ExampleFormat    *string `json:"example_format"`
+	DecimalDigits    *int32  `json:"decimal_digits"`
+	DecimalSeparator *string `json:"decimal_separator"`
+	SymbolFirst      *bool   `json:"symbol_first"`
+	GroupSeparator   *string `json:"group_separator"`
+	CurrencySymbol   *string `json:"currency_symbol"`
+	DisplaySymbol    *bool   `json:"display_symbol"`
+}
+
+// parquetBudgetRow is the budget itself, with its formats flattened into columns
+// like the budgets table of SQLite exports.
+type parquetBudgetRow struct {
+	ID                       string       `parquet:"id"`
+	Name                     string       `parquet:"name"`
+	LastModifiedOn           *time.Time   `parquet:"last_modified_on,timestamp(millisecond)"`
+	FirstMonth               *parquetDate `parquet:"first_month,date"`
+	LastMonth                *parquetDate `parquet:"last_month,date"`
+	DateFormat               *string      `parquet:"date_format"`
+	CurrencyISOCode          *string      `parquet:"currency_iso_code"`
+	CurrencyExampleFormat    *string      `parquet:"currency_example_format"`
+	CurrencyDecimalDigits    *int32       `parquet:"currency_decimal_digits"`
+	CurrencyDecimalSeparator *string      `parquet:"currency_decimal_separator"`
+	CurrencySymbolFirst      *bool        `parquet:"currency_symbol_first"`
+	CurrencyGroupSeparator   *string      `parquet:"currency_group_separator"`
+	CurrencySymbol           *string      `parquet:"currency_symbol"`
+	CurrencyDisplaySymbol    *bool        `parquet:"currency_display_symbol"`
+}
+
+type parquetAccount struct {
+	BudgetID                string     `json:"-" parquet:"budget_id"`
+	ID                      string     `json:"id" parquet:"id"`
+	Name                    string     `json:"name" parquet:"name"`
+	Type                    string     `json:"type" parquet:"type"`
+	OnBudget                bool       `json:"on_budget" parquet:"on_budget"`
+	Closed                  bool       `json:"closed" parquet:"closed"`
+	Note                    *string    `json:"note" parquet:"note"`
+	Balance                 int64      `json:"balance" parquet:"balance"`
+	BalanceDecimal          int64      `json:"-" parquet:"balance_decimal,decimal(3:18)"`
+	ClearedBalance          int64      `json:"cleared_balance" parquet:"cleared_balance"`
+	ClearedBalanceDecimal   int64      `json:"-" parquet:"cleared_balance_decimal,decimal(3:18)"`
+	UnclearedBalance        int64      `json:"uncleared_balance" parquet:"uncleared_balance"`
+	UnclearedBalanceDecimal int64      `json:"-" parquet:"uncleared_balance_decimal,decimal(3:18)"`
+	TransferPayeeID         *string    `json:"transfer_payee_id" parquet:"transfer_payee_id"`
+	DirectImportLinked      *bool      `json:"direct_import_linked" parquet:"direct_import_linked"`
+	DirectImportInError     *bool      `json:"direct_import_in_error" parquet:"direct_import_in_error"`
+	LastReconciledAt        *time.Time `json:"last_reconciled_at" parquet:"last_reconciled_at,timestamp(millisecond)"`
+	DebtOriginalBalance     *int64     `json:"debt_original_balance" parquet:"debt_original_balance"`
+	Deleted                 bool       `json:"deleted" parquet:"deleted"`
+}
+
+type parquetCategoryGroup struct {
+	BudgetID string `json:"-" parquet:"budget_id"`
+	ID       string `json:"id" parquet:"id"`
+	Name     string `json:"name" parquet:"name"`
+	Hidden   bool   `json:"hidden" parquet:"hidden"`
+	Deleted  bool   `json:"deleted" parquet:"deleted"`
+}
+
+type parquetCategory struct {
+	BudgetID                 string       `json:"-" parquet:"budget_id"`
+	ID                       string       `json:"id" parquet:"id"`
+	CategoryGroupID          string       `json:"category_group_id" parquet:"category_group_id"`
+	CategoryGroupName        *string      `json:"category_group_name" parquet:"category_group_name"`
+	Name                     string       `json:"name" parquet:"name"`
+	Hidden                   bool         `json:"hidden" parquet:"hidden"`
+	OriginalCategoryGroupID  *string      `json:"original_category_group_id" parquet:"original_category_group_id"`
+	Note                     *string      `json:"note" parquet:"note"`
+	Budgeted                 int64        `json:"budgeted" parquet:"budgeted"`
+	BudgetedDecimal          int64        `json:"-" parquet:"budgeted_decimal,decimal(3:18)"`
+	Activity                 int64        `json:"activity" parquet:"activity"`
+	ActivityDecimal          int64        `json:"-" parquet:"activity_decimal,decimal(3:18)"`
+	Balance                  int64        `json:"balance" parquet:"balance"`
+	BalanceDecimal           int64        `json:"-" parquet:"balance_decimal,decimal(3:18)"`
+	GoalType                 *string      `json:"goal_type" parquet:"goal_type"`
+	GoalNeedsWholeAmount     *bool        `json:"goal_needs_whole_amount" parquet:"goal_needs_whole_amount"`
+	GoalDay                  *int32       `json:"goal_day" parquet:"goal_day"`
+	GoalCadence              *int32       `json:"goal_cadence" parquet:"goal_cadence"`
+	GoalCadenceFrequency     *int32       `json:"goal_cadence_frequency" parquet:"goal_cadence_frequency"`
+	GoalCreationMonth        *parquetDate `json:"goal_creation_month" parquet:"goal_creation_month,date"`
+	GoalTarget               *int64       `json:"goal_target" parquet:"goal_target"`
+	GoalTargetDecimal        *int64       `json:"-" parquet:"goal_target_decimal,decimal(3:18)"`
+	GoalTargetMonth          *parquetDate `json:"goal_target_month" parquet:"goal_target_month,date"`
+	GoalPercentageComplete   *int32       `json:"goal_percentage_complete" parquet:"goal_percentage_complete"`
+	GoalMonthsToBudget       *int32       `json:"goal_months_to_budget" parquet:"goal_months_to_budget"`
+	GoalUnderFunded          *int64       `json:"goal_under_funded" parquet:"goal_under_funded"`
+	GoalUnderFundedDecimal   *int64       `json:"-" parquet:"goal_under_funded_decimal,decimal(3:18)"`
+	GoalOverallFunded        *int64       `json:"goal_overall_funded" parquet:"goal_overall_funded"`
+	GoalOverallFundedDecimal *int64       `json:"-" parquet:"goal_overall_funded_decimal,decimal(3:18)"`
+	GoalOverallLeft          *int64       `json:"goal_overall_left" parquet:"goal_overall_left"`
+	GoalOverallLeftDecimal   *int64       `json:"-" parquet:"goal_overall_left_decimal,decimal(3:18)"`
+	GoalSnoozedAt            *time.Time   `json:"goal_snoozed_at" parquet:"goal_snoozed_at,timestamp(millisecond)"`
+	Deleted                  bool         `json:"deleted" parquet:"deleted"`
+}
+
+type parquetPayee struct {
+	BudgetID          string  `json:"-" parquet:"budget_id"`
+	ID                string  `json:"id" parquet:"id"`
+	Name              string  `json:"name" parquet:"name"`
+	TransferAccountID *string `json:"transfer_account_id" parquet:"transfer_account_id"`
+	Deleted           bool    `json:"deleted" parquet:"deleted"`
+}
+
+// parquetPayeeLocation keeps the coordinates as the strings YNAB returns them as.
+type parquetPayeeLocation struct {
+	BudgetID  string `json:"-" parquet:"budget_id"`
+	ID        string `json:"id" parquet:"id"`
+	PayeeID   string `json:"payee_id" parquet:"payee_id"`
+	Latitude  string `json:"latitude" parquet:"latitude"`
+	Longitude string `json:"longitude" parquet:"longitude"`
+	Deleted   bool   `json:"deleted" parquet:"deleted"`
+}
+
+type parquetMonth struct {
+	BudgetID            string            `json:"-" parquet:"budget_id"`
+	Month               parquetDate       `json:"month" parquet:"month,date"`
+	Note                *string           `json:"note" parquet:"note"`
+	Income              int64             `json:"income" parquet:"income"`
+	IncomeDecimal       int64             `json:"-" parquet:"income_decimal,decimal(3:18)"`
+	Budgeted            int64             `json:"budgeted" parquet:"budgeted"`
+	BudgetedDecimal     int64             `json:"-" parquet:"budgeted_decimal,decimal(3:18)"`
+	Activity            int64             `json:"activity" parquet:"activity"`
+	ActivityDecimal     int64             `json:"-" parquet:"activity_decimal,decimal(3:18)"`
+	ToBeBudgeted        int64             `json:"to_be_budgeted" parquet:"to_be_budgeted"`
+	ToBeBudgetedDecimal int64             `json:"-" parquet:"to_be_budgeted_decimal,decimal(3:18)"`
+	AgeOfMoney          *int32            `json:"age_of_money" parquet:"age_of_money"`
+	Deleted             bool              `json:"deleted" parquet:"deleted"`
+	Categories          []parquetCategory `json:"categories" parquet:"-"` // Written as month_categories
+}
+
+// parquetMonthCategory is a category's amounts and goal in one month.
+type parquetMonthCategory struct {
+	Month parquetDate `parquet:"month,date"`
+	parquetCategory
+}
+
+type parquetTransaction struct {
+	BudgetID                string      `json:"-" parquet:"budget_id"`
+	ID                      string      `json:"id" parquet:"id"`
+	Date                    parquetDate `json:"date" parquet:"date,date"`
+	Amount                  int64       `json:"amount" parquet:"amount"`
+	AmountDecimal           int64       `json:"-" parquet:"amount_decimal,decimal(3:18)"`
+	Memo                    *string     `json:"memo" parquet:"memo"`
+	Cleared                 string      `json:"cleared" parquet:"cleared"`
+	Approved                bool        `json:"approved" parquet:"approved"`
+	FlagColor               *string     `json:"flag_color" parquet:"flag_color"`
+	FlagName                *string     `json:"flag_name" parquet:"flag_name"`
+	AccountID               string      `json:"account_id" parquet:"account_id"`
+	PayeeID                 *string     `json:"payee_id" parquet:"payee_id"`
+	CategoryID              *string     `json:"category_id" parquet:"category_id"`
+	TransferAccountID       *string     `json:"transfer_account_id" parquet:"transfer_account_id"`
+	TransferTransactionID   *string     `json:"transfer_transaction_id" parquet:"transfer_transaction_id"`
+	MatchedTransactionID    *string     `json:"matched_transaction_id" parquet:"matched_transaction_id"`
+	ImportID                *string     `json:"import_id" parquet:"import_id"`
+	ImportPayeeName         *string     `json:"import_payee_name" parquet:"import_payee_name"`
+	ImportPayeeNameOriginal *string     `json:"import_payee_name_original" parquet:"import_payee_name_original"`
+	DebtTransactionType     *string     `json:"debt_transaction_type" parquet:"debt_transaction_type"`
+	Deleted                 bool        `json:"deleted" parquet:"deleted"`
+}
+
+type parquetSubtransaction struct {
+	BudgetID              string  `json:"-" parquet:"budget_id"`
+	ID                    string  `json:"id" parquet:"id"`
+	TransactionID         string  `json:"transaction_id" parquet:"transaction_id"`
+	Amount                int64   `json:"amount" parquet:"amount"`
+	AmountDecimal         int64   `json:"-" parquet:"amount_decimal,decimal(3:18)"`
+	Memo                  *string `json:"memo" parquet:"memo"`
+	PayeeID               *string `json:"payee_id" parquet:"payee_id"`
+	PayeeName             *string `json:"payee_name" parquet:"payee_name"`
+	CategoryID            *string `json:"category_id" parquet:"category_id"`
+	CategoryName          *string `json:"category_name" parquet:"category_name"`
+	TransferAccountID     *string `json:"transfer_account_id" parquet:"transfer_account_id"`
+	TransferTransactionID *string `json:"transfer_transaction_id" parquet:"transfer_transaction_id"`
+	Deleted               bool    `json:"deleted" parquet:"deleted"`
+}
+
+type parquetScheduledTransaction struct {
+	BudgetID          string      `json:"-" parquet:"budget_id"`
+	ID                string      `json:"id" parquet:"id"`
+	DateFirst         parquetDate `json:"date_first" parquet:"date_first,date"`
+	DateNext          parquetDate `json:"date_next" parquet:"date_next,date"`
+	Frequency         string      `json:"frequency" parquet:"frequency"`
+	Amount            int64       `json:"amount" parquet:"amount"`
+	AmountDecimal     int64       `json:"-" parquet:"amount_decimal,decimal(3:18)"`
+	Memo              *string     `json:"memo" parquet:"memo"`
+	FlagColor         *string     `json:"flag_color" parquet:"flag_color"`
+	FlagName          *string     `json:"flag_name" parquet:"flag_name"`
+	AccountID         string      `json:"account_id" parquet:"account_id"`
+	PayeeID           *string     `json:"payee_id" parquet:"payee_id"`
+	CategoryID        *string     `json:"category_id" parquet:"category_id"`
+	TransferAccountID *string     `json:"transfer_account_id" parquet:"transfer_account_id"`
+	Deleted           bool        `json:"deleted" parquet:"deleted"`
+}
+
+type parquetScheduledSubtransaction struct {
+	BudgetID               string  `json:"-" parquet:"budget_id"`
+	ID                     string  `json:"id" parquet:"id"`
+	ScheduledTransactionID string  `json:"scheduled_transaction_id" parquet:"scheduled_transaction_id"`
+	Amount                 int64   `json:"amount" parquet:"amount"`
+	AmountDecimal          int64   `json:"-" parquet:"amount_decimal,decimal(3:18)"`
+	Memo                   *string `json:"memo" parquet:"memo"`
+	PayeeID                *string `json:"payee_id" parquet:"payee_id"`
+	CategoryID             *string `json:"category_id" parquet:"category_id"`
+	TransferAccountID      *string `json:"transfer_account_id" parquet:"transfer_account_id"`
+	Deleted                bool    `json:"deleted" parquet:"deleted"`
+}
+
+// secondsPerDay converts Unix times of midnight UTC to days.
+const secondsPerDay = 24 * 60 * 60
+
+// parquetDate is a YYYY-MM-DD date from the budget JSON, as the number of days
+// since the Unix epoch that Parquet stores DATE columns as.
+type parquetDate int32
+
+// UnmarshalJSON parses a YYYY-MM-DD date.
+func (d *parquetDate) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid date: %w", err)
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return fmt.Errorf("invalid date: %w", err)
+	}
+	*d = parquetDate(t.Unix() / secondsPerDay)
+	return nil
+}
+
+// readParquetBudget reads the entities of a budget downloaded to raw and fills in
+// their decimal columns.
+func readParquetBudget(raw io.ReadSeeker) (parquetBudget, error) {
+	if _, err := raw.Seek(0, io.SeekStart); err != nil {
+		return parquetBudget{}, fmt.Errorf("failed to read budget: %w", err)
+	}
+	var doc parquetDocument
+	if err := json.UnmarshalRead(raw, &doc); err != nil {
+		return parquetBudget{}, fmt.Errorf("failed to parse budget: %w", err)
+	}
+
+	b := doc.Data.Budget
+	for i := range b.Accounts {
+		a := &b.Accounts[i]
+		a.BudgetID = b.ID
+		a.BalanceDecimal, a.ClearedBalanceDecimal, a.UnclearedBalanceDecimal = a.Balance, a.ClearedBalance, a.UnclearedBalance
+	}
+	for i := range b.CategoryGroups {
+		b.CategoryGroups[i].BudgetID = b.ID
+	}
+	for i := range b.Categories {
+		b.Categories[i].fill(b.ID)
+	}
+	for i := range b.Payees {
+		b.Payees[i].BudgetID = b.ID
+	}
+	for i := range b.PayeeLocations {
+		b.PayeeLocations[i].BudgetID = b.ID
+	}
+	for i := range b.Months {
+		m := &b.Months[i]
+		m.BudgetID = b.ID
+		m.IncomeDecimal, m.BudgetedDecimal, m.ActivityDecimal, m.ToBeBudgetedDecimal = m.Income, m.Budgeted, m.Activity, m.ToBeBudgeted
+		for j := range m.Categories {
+			m.Categories[j].fill(b.ID)
+		}
+	}
+	for i := range b.Transactions {
+		t := &b.Transactions[i]
+		t.BudgetID, t.AmountDecimal = b.ID, t.Amount
+	}
+	for i := range b.Subtransactions {
+		st := &b.Subtransactions[i]
+		st.BudgetID, st.AmountDecimal = b.ID, st.Amount
+	}
+	for i := range b.ScheduledTransactions {
+		t := &b.ScheduledTransactions[i]
+		t.BudgetID, t.AmountDecimal = b.ID, t.Amount
+	}
+	for i := range b.ScheduledSubtransactions {
+		st := &b.ScheduledSubtransactions[i]
+		st.BudgetID, st.AmountDecimal = b.ID, st.Amount
+	}
+	return b, nil
+}
+
+// row returns the budget's own row.
+func (b parquetBudget) row() parquetBudgetRow {
+	row := parquetBudgetRow{
+		ID:             b.ID,
+		Name:           b.Name,
+		LastModifiedOn: b.LastModifiedOn,
+		FirstMonth:     b.FirstMonth,
+		LastMonth:      b.LastMonth,
+	}
+	if b.DateFormat != nil {
+		row.DateFormat = b.DateFormat.Format
+	}
+	if c := b.CurrencyFormat; c != nil {
+		row.CurrencyISOCode, row.CurrencyExampleFormat = c.ISOCode, c.ExampleFormat
+		row.CurrencyDecimalDigits, row.CurrencyDecimalSeparator = c.DecimalDigits, c.DecimalSeparator
+		row.CurrencySymbolFirst, row.CurrencyGroupSeparator = c.SymbolFirst, c.GroupSeparator
+		row.CurrencySymbol, row.CurrencyDisplaySymbol = c.CurrencySymbol, c.DisplaySymbol
+	}
+	return row
+}
+
+// fill sets the category's budget and copies its milliunit amounts to their
+// decimal columns.
+func (c *parquetCategory) fill(budgetID string) {
+	c.BudgetID = budgetID
+	c.BudgetedDecimal, c.ActivityDecimal, c.BalanceDecimal = c.Budgeted, c.Activity, c.Balance
+	c.GoalTargetDecimal, c.GoalUnderFundedDecimal = c.GoalTarget, c.GoalUnderFunded
+	c.GoalOverallFundedDecimal, c.GoalOverallLeftDecimal = c.GoalOverallFunded, c.GoalOverallLeft
+}
+
+// parts returns a file for each kind of entity in the budget.
+func (b parquetBudget) parts() []exportPart {
+	var monthCategories []parquetMonthCategory
+	for _, m := range b.Months {
+		for _, c := range m.Categories {
+			monthCategories = append(monthCategories, parquetMonthCategory{Month: m.Month, parquetCategory: c})
+		}
+	}
+
+	return []exportPart{
+		parquetPart("budgets", []parquetBudgetRow{b.row()}),
+		parquetPart("accounts", b.Accounts),
+		parquetPart("category-groups", b.CategoryGroups),
+		parquetPart("categories", b.Categories),
+		parquetPart("payees", b.Payees),
+		parquetPart("payee-locations", b.PayeeLocations),
+		parquetPart("months", b.Months),
+		parquetPart("month-categories", monthCategories),
+		parquetPart("transactions", b.Transactions),
+		parquetPart("subtransactions", b.Subtransactions),
+		parquetPart("scheduled-transactions", b.ScheduledTransactions),
+		parquetPart("scheduled-subtransactions", b.ScheduledSubtransactions),
+	}
+}
+
+// parquetPart returns the file that holds one kind of entity. Each file has a
+// schema even without rows, so loading it never depends on the budget's contents.
+// The schema is named after the entity, as the SQLite table of the same rows is.
+func parquetPart[T any](entity string, rows []T) exportPart {
+	return exportPart{name: entity, slug: entity, write: func(w io.Writer) error {
+		schema := parquet.NewSchema(strings.ReplaceAll(entity, "-", "_"), parquet.SchemaOf(new(T)))
+		pw := parquet.NewGenericWriter[T](w, schema,
+			parquet.Compression(&zstd.Codec{}),
+			parquet.CreatedBy("ynab-export", version, commit))
+		if _, err := pw.Write(rows); err != nil {
+			return fmt.Errorf("%w: %w", errWriteExport, err)
+		}
+		if err := pw.Close(); err != nil {
+			return fmt.Errorf("%w: %w", errWriteExport, err)
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetParts(t *testing.T) {
+	budget, err := readParquetBudget(bytes.NewReader(sqliteTestDownload(10, "Checking", false, "t-1")))
+	if err != nil {
+		t.Fatalf("readParquetBudget() error = %v", err)
+	}
+
+	files := make(map[string][]byte)
+	var schemaNames []string
+	for _, part := range budget.parts() {
+		var b bytes.Buffer
+		if err := part.write(&b); err != nil {
+			t.Fatalf("failed to write %s: %v", part.name, err)
+		}
+		f, err := parquet.OpenFile(bytes.NewReader(b.Bytes()), int64(b.Len()))
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", part.name, err)
+		}
+		schemaNames = append(schemaNames, f.Schema().Name())
+		if _, ok := f.Schema().Lookup("budget_id"); !ok && part.slug != "budgets" {
+			t.Errorf("%s has no budget_id column", part.name)
+		}
+		files[part.slug] = b.Bytes()
+	}
+
+	wantNames := []string{"budgets", "accounts", "category_groups", "categories", "payees", "payee_locations",
+		"months", "month_categories", "transactions", "subtransactions", "scheduled_transactions",
+		"scheduled_subtransactions"}
+	if len(schemaNames) != len(wantNames) {
+		t.Fatalf("schema names = %q, want %q", schemaNames, wantNames)
+	}
+	for i := range wantNames {
+		if schemaNames[i] != wantNames[i] {
+			t.Errorf("schema names = %q, want %q", schemaNames, wantNames)
+			break
+		}
+	}
+
+	budgets := readParquetRows[parquetBudgetRow](t, files["budgets"])
+	if len(budgets) != 1 {
+		t.Fatalf("budgets has %d rows, want 1", len(budgets))
+	}
+	b := budgets[0]
+	wantModified := time.Date(2025, 1, 12, 10, 0, 0, 0, time.UTC)
+	if b.ID != "b1" || b.Name != "Test Budget" || b.LastModifiedOn == nil || !b.LastModifiedOn.Equal(wantModified) {
+		t.Errorf("budget = %+v, want b1 Test Budget modified %v", b, wantModified)
+	}
+	if b.CurrencyISOCode == nil || *b.CurrencyISOCode != "USD" || b.CurrencyDecimalDigits == nil || *b.CurrencyDecimalDigits != 2 {
+		t.Errorf("budget currency = %v %v, want USD with 2 digits", b.CurrencyISOCode, b.CurrencyDecimalDigits)
+	}
+	if b.FirstMonth != nil || b.DateFormat != nil {
+		t.Errorf("missing budget fields = %v %v, want null", b.FirstMonth, b.DateFormat)
+	}
+
+	txns := readParquetRows[parquetTransaction](t, files["transactions"])
+	if len(txns) != 1 {
+		t.Fatalf("transactions has %d rows, want 1", len(txns))
+	}
+	if txn := txns[0]; txn.BudgetID != "b1" || txn.ID != "t-1" || txn.AmountDecimal != -45670 || txn.FlagColor != nil {
+		t.Errorf("transaction = %+v, want t-1 of b1 with amount_decimal -45670", txn)
+	}
+	wantDate, _ := time.Parse(time.DateOnly, "2025-01-03")
+	if got := time.Unix(int64(txns[0].Date)*secondsPerDay, 0).UTC(); !got.Equal(wantDate) {
+		t.Errorf("transaction date = %v, want %v", got, wantDate)
+	}
+
+	monthCategories := readParquetRows[parquetMonthCategory](t, files["month-categories"])
+	if len(monthCategories) != 1 {
+		t.Fatalf("month categories has %d rows, want 1", len(monthCategories))
+	}
+	if mc := monthCategories[0]; mc.BudgetID != "b1" || mc.ID != "c-groceries" || mc.BalanceDecimal != 54330 {
+		t.Errorf("month category = %+v, want c-groceries of b1 with balance_decimal 54330", mc)
+	}
+}
+
+func TestParquetDateUnmarshal(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    parquetDate
+		wantErr bool
+	}{
+		{json: `"1970-01-01"`, want: 0},
+		{json: `"1970-01-02"`, want: 1},
+		{json: `"2025-01-03"`, want: 20091},
+		{json: `"1969-12-31"`, want: -1},
+		{json: `"2025-13-01"`, wantErr: true},
+		{json: `20250103`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var d parquetDate
+		err := d.UnmarshalJSON([]byte(tt.json))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalJSON(%s) error = %v, wantErr %v", tt.json, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && d != tt.want {
+			t.Errorf("UnmarshalJSON(%s) = %d, want %d", tt.json, d, tt.want)
+		}
+	}
+}
+
+// readParquetRows reads back the rows of a Parquet file.
+func readParquetRows[T any](t *testing.T, data []byte) []T {
+	t.Helper()
+	rows, err := parquet.Read[T](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to read rows: %v", err)
+	}
+	return rows
+}
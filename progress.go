@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// transferProgress tracks the bytes received by one or more concurrent downloads,
+// so the interactive mode can show their progress. It is safe for concurrent use,
+// and a nil *transferProgress tracks nothing.
+type transferProgress struct {
+	start    time.Time
+	received atomic.Int64
+	expected atomic.Int64 // Sum of the Content-Length of the responses that have one
+	unknown  atomic.Bool  // Set when a response has no Content-Length
+}
+
+// newTransferProgress starts tracking a transfer.
+func newTransferProgress() *transferProgress {
+	return &transferProgress{start: time.Now()}
+}
+
+// expect records the size of a response that is about to be read, or -1 if unknown.
+func (p *transferProgress) expect(contentLength int64) {
+	if p == nil {
+		return
+	}
+	if contentLength < 0 {
+		p.unknown.Store(true)
+		return
+	}
+	p.expected.Add(contentLength)
+}
+
+// reader wraps r to count the bytes read from it.
+func (p *transferProgress) reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &progressReader{r: r, progress: p}
+}
+
+// fraction returns how much of the transfer is complete, from 0 to 1, and whether
+// that is known.
+func (p *transferProgress) fraction() (float64, bool) {
+	expected := p.expected.Load()
+	if p.unknown.Load() || expected <= 0 {
+		return 0, false
+	}
+	return min(float64(p.received.Load())/float64(expected), 1), true
+}
+
+// String describes the bytes received, throughput and elapsed time, such as
+// "1.20 MB of 3.50 MB • 512.00 KB/s • 3s".
+func (p *transferProgress) String() string {
+	received := p.received.Load()
+	elapsed := time.Since(p.start)
+
+	size := humanizeFileSize(received)
+	if _, ok := p.fraction(); ok {
+		size += " of " + humanizeFileSize(p.expected.Load())
+	}
+	var throughput int64
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		throughput = int64(float64(received) / seconds)
+	}
+	return fmt.Sprintf("%s • %s/s • %s", size, humanizeFileSize(throughput), elapsed.Round(time.Second))
+}
+
+// progressReader counts the bytes read through it into a transferProgress.
+type progressReader struct {
+	r        io.Reader
+	progress *transferProgress
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.progress.received.Add(int64(n))
+	return n, err //nolint:wrapcheck // Transparent pass-through reader
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// qifDateFormat is the US date format that Quicken and most QIF importers expect.
+const qifDateFormat = "01/02/2006"
+
+// qifNameReplacer removes the characters QIF gives a meaning in category names:
+// ":" separates a subcategory and "/" a class.
+var qifNameReplacer = strings.NewReplacer(": ", " - ", ":", "-", "/", "-")
+
+// writeQIF writes the budget's transactions to w as QIF, with an account header
+// and a section of the account's type for each account. Split transactions are
+// written with a split line per subtransaction.
+func writeQIF(w io.Writer, budget budgetDetail) error {
+	idx := newBudgetIndex(budget)
+	bw := bufio.NewWriter(w)
+
+	// Write errors are sticky in bufio.Writer, so checking Flush is enough
+	for _, acc := range budget.Accounts {
+		if acc.Deleted {
+			continue
+		}
+		accountType := qifAccountType(acc.Type)
+		fmt.Fprintf(bw, "!Account\nN%s\nT%s\n^\n!Type:%s\n", qifText(acc.Name), accountType, accountType)
+
+		for _, t := range accountTransactions(budget, acc.ID) {
+			fmt.Fprintf(bw, "D%s\nT%s\n", qifDate(t.Date), budget.CurrencyFormat.decimal(t.Amount))
+			switch t.Cleared {
+			case "cleared":
+				fmt.Fprint(bw, "C*\n")
+			case "reconciled":
+				fmt.Fprint(bw, "CX\n")
+			}
+			if payee := idx.payees[t.PayeeID]; payee != "" {
+				fmt.Fprintf(bw, "P%s\n", qifText(payee))
+			}
+			if t.Memo != "" {
+				fmt.Fprintf(bw, "M%s\n", qifText(t.Memo))
+			}
+
+			parts, split := idx.splits[t.ID]
+			if !split {
+				if category := qifCategory(idx, t.CategoryID, t.TransferAccountID); category != "" {
+					fmt.Fprintf(bw, "L%s\n", category)
+				}
+			}
+			for _, st := range parts {
+				if category := qifCategory(idx, st.CategoryID, st.TransferAccountID); category != "" {
+					fmt.Fprintf(bw, "S%s\n", category)
+				}
+				if st.Memo != "" {
+					fmt.Fprintf(bw, "E%s\n", qifText(st.Memo))
+				}
+				fmt.Fprintf(bw, "$%s\n", budget.CurrencyFormat.decimal(st.Amount))
+			}
+			fmt.Fprint(bw, "^\n")
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	return nil
+}
+
+// qifAccountType maps a YNAB account type to a QIF account type. Loans and other
+// debts are liabilities.
+func qifAccountType(accountType string) string {
+	switch accountType {
+	case accountTypeChecking, accountTypeSavings:
+		return "Bank"
+	case accountTypeCreditCard, accountTypeLineOfCredit:
+		return "CCard"
+	case accountTypeCash:
+		return "Cash"
+	case accountTypeOtherAsset:
+		return "Oth A"
+	}
+	return "Oth L"
+}
+
+// qifCategory returns the QIF category of a transaction or split: [Account Name]
+// for a transfer, otherwise Group:Category.
+func qifCategory(idx budgetIndex, categoryID, transferAccountID string) string {
+	if transferAccountID != "" {
+		return "[" + qifText(idx.accountName(transferAccountID)) + "]"
+	}
+	group, name := idx.category(categoryID)
+	switch {
+	case name == "":
+		return ""
+	case group == "":
+		return qifText(qifNameReplacer.Replace(name))
+	}
+	return qifText(qifNameReplacer.Replace(group) + ":" + qifNameReplacer.Replace(name))
+}
+
+// qifDate converts a YYYY-MM-DD date to the QIF date format.
+func qifDate(date string) string {
+	t, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return date
+	}
+	return t.Format(qifDateFormat)
+}
+
+// qifText makes s fit on a single QIF line.
+func qifText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteQIF(t *testing.T) {
+	var b strings.Builder
+	if err := writeQIF(&b, testBudget()); err != nil {
+		t.Fatalf("writeQIF() error = %v", err)
+	}
+
+	want := `!Account
+NChecking
+TBank
+^
+!Type:Bank
+D01/03/2025
+T-45.67
+CX
+PGrocer
+MWeekly shop
+LFood:Groceries
+^
+D01/05/2025
+T2000.00
+C*
+PEmployer
+LInternal Master Category:Inflow - Ready to Assign
+^
+D01/10/2025
+T-120.00
+C*
+PGrocer
+MBig shop
+SFood:Groceries
+EFood
+$-80.00
+SBills:Rent
+$-40.00
+^
+D01/12/2025
+T-500.00
+PTransfer : Savings
+L[Savings]
+^
+!Account
+NVisa
+TCCard
+^
+!Type:CCard
+D01/08/2025
+T-12.34
+PGrocer
+LFood:Groceries
+^
+!Account
+NSavings
+TBank
+^
+!Type:Bank
+D01/12/2025
+T500.00
+C*
+PTransfer : Checking
+L[Checking]
+^
+`
+	if got := b.String(); got != want {
+		t.Errorf("writeQIF() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestQIFAccountType(t *testing.T) {
+	tests := []struct {
+		accountType string
+		want        string
+	}{
+		{accountType: accountTypeChecking, want: "Bank"},
+		{accountType: accountTypeSavings, want: "Bank"},
+		{accountType: accountTypeCreditCard, want: "CCard"},
+		{accountType: accountTypeLineOfCredit, want: "CCard"},
+		{accountType: accountTypeCash, want: "Cash"},
+		{accountType: accountTypeOtherAsset, want: "Oth A"},
+		{accountType: "mortgage", want: "Oth L"},
+	}
+	for _, tt := range tests {
+		if got := qifAccountType(tt.accountType); got != tt.want {
+			t.Errorf("qifAccountType(%q) = %q, want %q", tt.accountType, got, tt.want)
+		}
+	}
+}
+
+func TestQIFCategory(t *testing.T) {
+	budget := testBudget()
+	budget.CategoryGroups = append(budget.CategoryGroups, categoryGroup{ID: "g-home", Name: "Home/Garden"})
+	budget.Categories = append(budget.Categories,
+		category{ID: "c-tools", CategoryGroupID: "g-home", Name: "Tools:Hand"},
+		category{ID: "c-orphan", Name: "Orphan"})
+	idx := newBudgetIndex(budget)
+
+	tests := []struct {
+		name       string
+		categoryID string
+		transferID string
+		want       string
+	}{
+		{name: "group and category", categoryID: "c-groceries", want: "Food:Groceries"},
+		{name: "reserved characters replaced", categoryID: "c-tools", want: "Home-Garden:Tools-Hand"},
+		{name: "colon and space replaced", categoryID: "c-rta", want: "Internal Master Category:Inflow - Ready to Assign"},
+		{name: "no group", categoryID: "c-orphan", want: "Orphan"},
+		{name: "transfer", transferID: "a-sav", want: "[Savings]"},
+		{name: "uncategorized", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := qifCategory(idx, tt.categoryID, tt.transferID); got != tt.want {
+				t.Errorf("qifCategory(%q, %q) = %q, want %q", tt.categoryID, tt.transferID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQIFText(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "Weekly shop", want: "Weekly shop"},
+		{s: "Line one\nline two", want: "Line one line two"},
+		{s: "  padded\t memo ", want: "padded memo"},
+	}
+	for _, tt := range tests {
+		if got := qifText(tt.s); got != tt.want {
+			t.Errorf("qifText(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// YNAB allows each access token 200 requests in a rolling one-hour window.
+const (
+	defaultRateLimit = 200
+	rateLimitWindow  = time.Hour
+)
+
+const rateLimitFileName = "ratelimit.json"
+
+var errRateLimited = errors.New("YNAB API rate limit reached")
+
+// requestLog is the persisted record of the requests made with one token.
+type requestLog struct {
+	Requests []time.Time `json:"requests"`
+	Limit    int         `json:"limit,omitempty"` // From the X-Rate-Limit header
+	// Usage reported by the X-Rate-Limit header, which also counts requests made
+	// with the token elsewhere
+	ServerUsed int       `json:"server_used,omitempty"`
+	ServerAt   time.Time `json:"server_at,omitzero"`
+}
+
+// rateTracker keeps track of the requests made with a token in the current window,
+// so runs that would exceed the rate limit can be detected before they start.
+type rateTracker struct {
+	key string // Hash of the token, so the token itself is not stored
+	log requestLog
+	mu  sync.Mutex
+}
+
+// rateLimitFileMu serializes updates to the rate limit file.
+var rateLimitFileMu sync.Mutex
+
+// getRateLimitPath returns the path to the file recording recent requests.
+// It is kept next to the cached token.
+func getRateLimitPath() (string, error) {
+	tokenPath, err := getTokenCachePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(tokenPath), rateLimitFileName), nil
+}
+
+// loadRateTracker loads the recorded requests for token. If the record cannot be
+// read, tracking starts from scratch.
+func loadRateTracker(token string) *rateTracker {
+	sum := sha256.Sum256([]byte(token))
+	t := &rateTracker{key: hex.EncodeToString(sum[:8])}
+
+	rateLimitFileMu.Lock()
+	defer rateLimitFileMu.Unlock()
+	logs, err := loadRequestLogs()
+	if err == nil {
+		t.log = logs[t.key]
+	}
+	return t
+}
+
+// loadRequestLogs reads the request logs of all tokens, keyed by token hash.
+func loadRequestLogs() (map[string]requestLog, error) {
+	logs := make(map[string]requestLog)
+
+	path, err := getRateLimitPath()
+	if err != nil {
+		return logs, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return logs, nil // No requests recorded yet
+		}
+		return logs, fmt.Errorf("failed to read request log from %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &logs); err != nil {
+		return logs, fmt.Errorf("invalid request log %s: %w", path, err)
+	}
+	return logs, nil
+}
+
+// record counts a request that got resp, and saves the updated log.
+func (t *rateTracker) record(resp *http.Response, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+	t.log.Requests = append(t.log.Requests, now)
+	if used, limit, ok := parseRateLimitHeader(resp.Header.Get("X-Rate-Limit")); ok {
+		t.log.ServerUsed, t.log.ServerAt, t.log.Limit = used, now, limit
+	}
+
+	// A failure only makes the next run's estimate less accurate
+	_ = t.save() //nolint:errcheck // Best effort, the server enforces the limit anyway
+}
+
+// remaining returns the number of requests still available in the current window.
+func (t *rateTracker) remaining(now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+	return max(t.limit()-t.used(), 0)
+}
+
+// waitFor returns how long until n more requests are available. Each recorded
+// request frees up a slot when it leaves the window; usage reported by the server
+// but not made here is assumed to clear when the window has passed. When n is
+// more than the limit allows at once, it returns how long until the window is empty.
+func (t *rateTracker) waitFor(n int, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+	used := t.used()
+	expiring := used + n - t.limit() // Requests that must leave the window first
+	switch {
+	case expiring <= 0:
+		return 0
+	case t.log.ServerUsed <= len(t.log.Requests) && expiring <= len(t.log.Requests):
+		return t.log.Requests[expiring-1].Add(rateLimitWindow).Sub(now)
+	case !t.log.ServerAt.IsZero():
+		return t.log.ServerAt.Add(rateLimitWindow).Sub(now)
+	case len(t.log.Requests) > 0:
+		return t.log.Requests[len(t.log.Requests)-1].Add(rateLimitWindow).Sub(now)
+	}
+	return rateLimitWindow
+}
+
+// used returns the number of requests made in the current window. The caller must hold t.mu.
+func (t *rateTracker) used() int {
+	return max(len(t.log.Requests), t.log.ServerUsed)
+}
+
+// limit returns the number of requests allowed per window.
+func (t *rateTracker) limit() int {
+	if t.log.Limit > 0 {
+		return t.log.Limit
+	}
+	return defaultRateLimit
+}
+
+// prune forgets requests that have left the window. The caller must hold t.mu.
+func (t *rateTracker) prune(now time.Time) {
+	cutoff := now.Add(-rateLimitWindow)
+	i := 0
+	for i < len(t.log.Requests) && !t.log.Requests[i].After(cutoff) {
+		i++
+	}
+	t.log.Requests = t.log.Requests[i:]
+	if !t.log.ServerAt.After(cutoff) {
+		t.log.ServerUsed, t.log.ServerAt = 0, time.Time{}
+	}
+}
+
+// save writes the log to the rate limit file. The caller must hold t.mu.
+func (t *rateTracker) save() error {
+	rateLimitFileMu.Lock()
+	defer rateLimitFileMu.Unlock()
+
+	logs, err := loadRequestLogs()
+	if err != nil {
+		logs = make(map[string]requestLog) // Replace an unreadable log
+	}
+	logs[t.key] = t.log
+
+	data, err := json.Marshal(logs, jsontext.WithIndent("  "), json.Deterministic(true))
+	if err != nil {
+		return fmt.Errorf("failed to encode request log: %w", err)
+	}
+	path, err := getRateLimitPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if _, err := writeFileAtomic(path, data, overwriteReplace); err != nil {
+		return fmt.Errorf("failed to save request log: %w", err)
+	}
+	return nil
+}
+
+// parseRateLimitHeader parses an X-Rate-Limit header such as "36/200" into the
+// number of requests used and the limit.
+func parseRateLimitHeader(header string) (int, int, bool) {
+	usedStr, limitStr, ok := strings.Cut(header, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	used, err := strconv.Atoi(strings.TrimSpace(usedStr))
+	if err != nil {
+		return 0, 0, false
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+	if err != nil || limit <= 0 {
+		return 0, 0, false
+	}
+	return used, limit, true
+}
+
+// parseRetryAfter returns the delay requested by a Retry-After header, given either
+// in seconds or as an HTTP date. Without a usable header, it waits for the window
+// to pass.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header)); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return rateLimitWindow
+}
+
+// rateLimitedError describes a rate limit that was hit or would be hit, and when
+// requests are available again.
+func rateLimitedError(wait time.Duration) error {
+	return fmt.Errorf("%w: try again in %s, or use --wait-rate-limit to wait", errRateLimited, formatWait(wait))
+}
+
+// formatWait formats a wait time for display, rounded to the second.
+func formatWait(d time.Duration) string {
+	return max(d, 0).Round(time.Second).String()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitHeader(t *testing.T) {
+	tests := []struct {
+		header    string
+		wantUsed  int
+		wantLimit int
+		wantOK    bool
+	}{
+		{header: "36/200", wantUsed: 36, wantLimit: 200, wantOK: true},
+		{header: " 0 / 200 ", wantUsed: 0, wantLimit: 200, wantOK: true},
+		{header: "200/200", wantUsed: 200, wantLimit: 200, wantOK: true},
+		{header: ""},
+		{header: "36"},
+		{header: "a/200"},
+		{header: "36/b"},
+		{header: "36/0"},
+	}
+	for _, tt := range tests {
+		used, limit, ok := parseRateLimitHeader(tt.header)
+		if used != tt.wantUsed || limit != tt.wantLimit || ok != tt.wantOK {
+			t.Errorf("parseRateLimitHeader(%q) = %d, %d, %t, want %d, %d, %t",
+				tt.header, used, limit, ok, tt.wantUsed, tt.wantLimit, tt.wantOK)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{header: "120", want: 2 * time.Minute},
+		{header: " 0 ", want: 0},
+		{header: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second},
+		{header: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{header: "", want: rateLimitWindow},
+		{header: "-5", want: rateLimitWindow},
+		{header: "soon", want: rateLimitWindow},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRateTrackerWaitFor(t *testing.T) {
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	// requestsSince returns n requests made every minute, starting at start minutes ago.
+	requestsSince := func(start, n int) []time.Time {
+		requests := make([]time.Time, n)
+		for i := range requests {
+			requests[i] = now.Add(time.Duration(i-start) * time.Minute)
+		}
+		return requests
+	}
+
+	tests := []struct {
+		name   string
+		log    requestLog
+		needed int
+		want   time.Duration
+	}{
+		{name: "nothing recorded", needed: 1, want: 0},
+		{name: "fits", log: requestLog{Limit: 10, Requests: requestsSince(30, 9)}, needed: 1, want: 0},
+		{
+			name:   "waits for the oldest request",
+			log:    requestLog{Limit: 10, Requests: requestsSince(50, 10)},
+			needed: 1,
+			want:   10 * time.Minute,
+		},
+		{
+			name:   "waits for several requests",
+			log:    requestLog{Limit: 10, Requests: requestsSince(50, 10)},
+			needed: 3,
+			want:   12 * time.Minute,
+		},
+		{
+			name:   "expired requests are forgotten",
+			log:    requestLog{Limit: 10, Requests: append(requestsSince(90, 5), requestsSince(30, 5)...)},
+			needed: 5,
+			want:   0,
+		},
+		{
+			name:   "server usage",
+			log:    requestLog{Limit: 10, ServerUsed: 10, ServerAt: now.Add(-20 * time.Minute), Requests: requestsSince(20, 2)},
+			needed: 1,
+			want:   40 * time.Minute,
+		},
+		{
+			name:   "more than the limit",
+			log:    requestLog{Limit: 10, Requests: requestsSince(50, 5)},
+			needed: 20,
+			want:   14 * time.Minute,
+		},
+		{
+			name:   "more than the limit with nothing recorded",
+			log:    requestLog{Limit: 10},
+			needed: 20,
+			want:   rateLimitWindow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &rateTracker{log: tt.log}
+			got := tracker.waitFor(tt.needed, now)
+			if got != tt.want {
+				t.Errorf("waitFor(%d) = %s, want %s", tt.needed, got, tt.want)
+			}
+			if got < 0 {
+				t.Errorf("waitFor(%d) is negative", tt.needed)
+			}
+		})
+	}
+}
+
+func TestFormatWait(t *testing.T) {
+	tests := []struct {
+		wait time.Duration
+		want string
+	}{
+		{wait: 0, want: "0s"},
+		{wait: -time.Minute, want: "0s"},
+		{wait: 1500 * time.Millisecond, want: "2s"},
+		{wait: 61 * time.Minute, want: "1h1m0s"},
+	}
+	for _, tt := range tests {
+		if got := formatWait(tt.wait); got != tt.want {
+			t.Errorf("formatWait(%s) = %q, want %q", tt.wait, got, tt.want)
+		}
+	}
+}
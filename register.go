@@ -0,0 +1,136 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+)
+
+// budgetIndex looks up the accounts, payees and categories that a budget's
+// transactions refer to by ID.
+type budgetIndex struct {
+	accounts   map[string]account
+	payees     map[string]string
+	groups     map[string]string
+	categories map[string]category
+	splits     map[string][]subtransaction // Parts of each split transaction, by transaction ID
+}
+
+// newBudgetIndex indexes a budget. Deleted subtransactions are left out.
+func newBudgetIndex(budget budgetDetail) budgetIndex {
+	idx := budgetIndex{
+		accounts:   make(map[string]account, len(budget.Accounts)),
+		payees:     make(map[string]string, len(budget.Payees)),
+		groups:     make(map[string]string, len(budget.CategoryGroups)),
+		categories: make(map[string]category, len(budget.Categories)),
+		splits:     make(map[string][]subtransaction),
+	}
+	for _, a := range budget.Accounts {
+		idx.accounts[a.ID] = a
+	}
+	for _, p := range budget.Payees {
+		idx.payees[p.ID] = p.Name
+	}
+	for _, g := range budget.CategoryGroups {
+		idx.groups[g.ID] = g.Name
+	}
+	for _, c := range budget.Categories {
+		idx.categories[c.ID] = c
+	}
+	for _, st := range budget.Subtransactions {
+		if !st.Deleted {
+			idx.splits[st.TransactionID] = append(idx.splits[st.TransactionID], st)
+		}
+	}
+	return idx
+}
+
+// accountName returns the name of an account, or "" if id is empty or unknown.
+func (idx budgetIndex) accountName(id string) string {
+	return idx.accounts[id].Name
+}
+
+// category returns the names of a category's group and of the category itself.
+func (idx budgetIndex) category(id string) (group, name string) {
+	c := idx.categories[id]
+	return idx.groups[c.CategoryGroupID], c.Name
+}
+
+// accountTransactions returns the transactions of an account in date order,
+// leaving out deleted ones.
+func accountTransactions(budget budgetDetail, accountID string) []transaction {
+	var txns []transaction
+	for _, t := range budget.Transactions {
+		if t.AccountID == accountID && !t.Deleted {
+			txns = append(txns, t)
+		}
+	}
+	slices.SortStableFunc(txns, func(a, b transaction) int { return cmp.Compare(a.Date, b.Date) })
+	return txns
+}
+
+// registerEntry is one row of a transaction register: a transaction, or one part of
+// a split transaction, with the names of everything it refers to looked up.
+type registerEntry struct {
+	ID              string // Transaction ID, or subtransaction ID for a split
+	ParentID        string // ID of the split transaction this is a part of
+	Date            string // YYYY-MM-DD
+	Account         string
+	Payee           string
+	CategoryGroup   string
+	Category        string
+	Memo            string
+	Cleared         string // "cleared", "uncleared" or "reconciled"
+	Flag            string // Flag name, or color if the flag is not named
+	TransferAccount string
+	Amount          int64 // Milliunits; negative for outflows
+	Approved        bool
+}
+
+// budgetRegister builds the transaction register of a budget, in date order.
+// Split transactions become one entry per split, and deleted transactions are left out.
+func budgetRegister(budget budgetDetail) []registerEntry {
+	idx := newBudgetIndex(budget)
+
+	entries := make([]registerEntry, 0, len(budget.Transactions)+len(budget.Subtransactions))
+	for _, t := range budget.Transactions {
+		if t.Deleted {
+			continue
+		}
+		entry := registerEntry{
+			ID:              t.ID,
+			Date:            t.Date,
+			Account:         idx.accountName(t.AccountID),
+			Payee:           idx.payees[t.PayeeID],
+			Memo:            t.Memo,
+			Cleared:         t.Cleared,
+			Flag:            cmp.Or(t.FlagName, t.FlagColor),
+			TransferAccount: idx.accountName(t.TransferAccountID),
+			Amount:          t.Amount,
+			Approved:        t.Approved,
+		}
+
+		parts, split := idx.splits[t.ID]
+		if !split {
+			entry.CategoryGroup, entry.Category = idx.category(t.CategoryID)
+			entries = append(entries, entry)
+			continue
+		}
+
+		// Each part inherits what it does not set itself from the split transaction
+		for _, st := range parts {
+			part := entry
+			part.ID, part.ParentID = st.ID, t.ID
+			part.Payee = cmp.Or(idx.payees[st.PayeeID], entry.Payee)
+			part.Memo = cmp.Or(st.Memo, entry.Memo)
+			part.TransferAccount = idx.accountName(st.TransferAccountID)
+			part.Amount = st.Amount
+			part.CategoryGroup, part.Category = idx.category(st.CategoryID)
+			entries = append(entries, part)
+		}
+	}
+
+	slices.SortStableFunc(entries, func(a, b registerEntry) int {
+		return cmp.Compare(a.Date, b.Date)
+	})
+	return entries
+}
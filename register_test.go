@@ -0,0 +1,124 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// testBudget returns a small budget with a split, a transfer and deleted
+// entities, shared by the tests of the converted formats.
+func testBudget() budgetDetail {
+	return budgetDetail{
+		ID:   "b1",
+		Name: "Test Budget",
+		CurrencyFormat: currencyFormat{ISOCode: "USD", CurrencySymbol: "$", DecimalSeparator: ".", GroupSeparator: ",",
+			DecimalDigits: 2, SymbolFirst: true, DisplaySymbol: true},
+		Accounts: []account{
+			{ID: "a-chk", Name: "Checking", Type: accountTypeChecking, Balance: 1321990, ClearedBalance: 1334330, OnBudget: true},
+			{ID: "a-visa", Name: "Visa", Type: accountTypeCreditCard, Balance: -12340, OnBudget: true},
+			{ID: "a-sav", Name: "Savings", Type: accountTypeSavings, Balance: 500000, ClearedBalance: 500000, OnBudget: true},
+			{ID: "a-old", Name: "Old", Type: accountTypeChecking, Deleted: true},
+		},
+		Payees: []payee{
+			{ID: "p-employer", Name: "Employer"},
+			{ID: "p-grocer", Name: "Grocer"},
+			{ID: "p-to-sav", Name: "Transfer : Savings"},
+			{ID: "p-to-chk", Name: "Transfer : Checking"},
+		},
+		CategoryGroups: []categoryGroup{
+			{ID: "g-internal", Name: "Internal Master Category"},
+			{ID: "g-bills", Name: "Bills"},
+			{ID: "g-food", Name: "Food"},
+		},
+		Categories: []category{
+			{ID: "c-rta", CategoryGroupID: "g-internal", Name: "Inflow: Ready to Assign"},
+			{ID: "c-rent", CategoryGroupID: "g-bills", Name: "Rent"},
+			{ID: "c-groceries", CategoryGroupID: "g-food", Name: "Groceries"},
+		},
+		Transactions: []transaction{
+			{ID: "t-pay", Date: "2025-01-05", AccountID: "a-chk", PayeeID: "p-employer", CategoryID: "c-rta",
+				Cleared: "cleared", Amount: 2000000, Approved: true},
+			{ID: "t-shop", Date: "2025-01-03", AccountID: "a-chk", PayeeID: "p-grocer", CategoryID: "c-groceries",
+				Memo: "Weekly shop", Cleared: "reconciled", FlagColor: "red", Amount: -45670, Approved: true},
+			{ID: "t-split", Date: "2025-01-10", AccountID: "a-chk", PayeeID: "p-grocer", Memo: "Big shop",
+				Cleared: "cleared", FlagColor: "blue", FlagName: "Review", Amount: -120000, Approved: true},
+			{ID: "t-to-sav", Date: "2025-01-12", AccountID: "a-chk", PayeeID: "p-to-sav", TransferAccountID: "a-sav",
+				TransferTransactionID: "t-from-chk", Cleared: "uncleared", Amount: -500000, Approved: true},
+			{ID: "t-from-chk", Date: "2025-01-12", AccountID: "a-sav", PayeeID: "p-to-chk", TransferAccountID: "a-chk",
+				TransferTransactionID: "t-to-sav", Cleared: "cleared", Amount: 500000, Approved: true},
+			{ID: "t-card", Date: "2025-01-08", AccountID: "a-visa", PayeeID: "p-grocer", CategoryID: "c-groceries",
+				Cleared: "uncleared", Amount: -12340},
+			{ID: "t-gone", Date: "2025-01-01", AccountID: "a-chk", PayeeID: "p-grocer", CategoryID: "c-groceries",
+				Amount: -999000, Deleted: true},
+		},
+		Subtransactions: []subtransaction{
+			{ID: "s-food", TransactionID: "t-split", CategoryID: "c-groceries", Memo: "Food", Amount: -80000},
+			{ID: "s-rent", TransactionID: "t-split", CategoryID: "c-rent", Amount: -40000},
+			{ID: "s-gone", TransactionID: "t-split", CategoryID: "c-rent", Amount: -1000, Deleted: true},
+		},
+	}
+}
+
+func TestBudgetRegister(t *testing.T) {
+	entries := budgetRegister(testBudget())
+
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	wantIDs := []string{"t-shop", "t-pay", "t-card", "s-food", "s-rent", "t-to-sav", "t-from-chk"}
+	if !slices.Equal(ids, wantIDs) {
+		t.Fatalf("register entries = %q, want %q", ids, wantIDs)
+	}
+
+	tests := []struct {
+		name string
+		got  registerEntry
+		want registerEntry
+	}{
+		{
+			name: "plain transaction",
+			got:  entries[0],
+			want: registerEntry{ID: "t-shop", Date: "2025-01-03", Account: "Checking", Payee: "Grocer", CategoryGroup: "Food",
+				Category: "Groceries", Memo: "Weekly shop", Cleared: "reconciled", Flag: "red", Amount: -45670, Approved: true},
+		},
+		{
+			name: "split with its own memo",
+			got:  entries[3],
+			want: registerEntry{ID: "s-food", ParentID: "t-split", Date: "2025-01-10", Account: "Checking", Payee: "Grocer",
+				CategoryGroup: "Food", Category: "Groceries", Memo: "Food", Cleared: "cleared", Flag: "Review",
+				Amount: -80000, Approved: true},
+		},
+		{
+			name: "split inheriting the memo",
+			got:  entries[4],
+			want: registerEntry{ID: "s-rent", ParentID: "t-split", Date: "2025-01-10", Account: "Checking", Payee: "Grocer",
+				CategoryGroup: "Bills", Category: "Rent", Memo: "Big shop", Cleared: "cleared", Flag: "Review",
+				Amount: -40000, Approved: true},
+		},
+		{
+			name: "transfer",
+			got:  entries[5],
+			want: registerEntry{ID: "t-to-sav", Date: "2025-01-12", Account: "Checking", Payee: "Transfer : Savings",
+				Cleared: "uncleared", TransferAccount: "Savings", Amount: -500000, Approved: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("entry = %+v, want %+v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountTransactions(t *testing.T) {
+	var ids []string
+	for _, txn := range accountTransactions(testBudget(), "a-chk") {
+		ids = append(ids, txn.ID)
+	}
+	want := []string{"t-shop", "t-pay", "t-split", "t-to-sav"}
+	if !slices.Equal(ids, want) {
+		t.Errorf("accountTransactions() = %q, want %q", ids, want)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxSlugLength limits budget name slugs (in bytes) so generated file names stay
+// well under the 255-byte limit of common filesystems.
+const maxSlugLength = 64
+
+// windowsReservedNames are device names that cannot be used as file names on Windows,
+// with or without an extension.
+var windowsReservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true,
+	"com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true,
+	"lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// slugify converts a budget name into a lowercase file name component that is safe
+// on all platforms. Letters and digits are kept (accents are removed from Latin
+// letters), and every run of other characters, such as spaces, path separators,
+// punctuation and emoji, becomes a single dash.
+// If nothing usable remains, the slug of fallback (e.g. the budget ID) is returned.
+func slugify(name, fallback string) string {
+	// Decompose characters so accented letters become a base letter plus combining marks,
+	// and compatibility characters (ligatures, full-width forms) become their plain form
+	decomposed := norm.NFKD.String(name)
+
+	var b strings.Builder
+	pendingDash := false
+	afterLatin := false
+	for _, r := range decomposed {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Drop accents from Latin letters, but keep marks that are part of other
+			// scripts (e.g. Japanese dakuten) so they can be recomposed below
+			if !afterLatin && b.Len() > 0 && !pendingDash {
+				b.WriteRune(r)
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if pendingDash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingDash = false
+			afterLatin = unicode.Is(unicode.Latin, r)
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			pendingDash = true
+		}
+	}
+	slug := truncateSlug(norm.NFC.String(b.String()), maxSlugLength)
+
+	if windowsReservedNames[slug] {
+		slug += "-budget"
+	}
+
+	if slug == "" {
+		if fallback == "" {
+			return "budget"
+		}
+		return slugify(fallback, "")
+	}
+	return slug
+}
+
+// truncateSlug shortens slug to at most maxBytes without splitting a character,
+// and without leaving a trailing dash.
+func truncateSlug(slug string, maxBytes int) string {
+	if len(slug) <= maxBytes {
+		return slug
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(slug[cut]) {
+		cut--
+	}
+	return strings.TrimRight(slug[:cut], "-")
+}
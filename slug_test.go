@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	const budgetID = "0B0F6A9E-5A0C-4C4E-9D4B-1F1D1E5C0A01"
+
+	tests := []struct {
+		name     string
+		input    string
+		fallback string
+		want     string
+	}{
+		{name: "plain", input: "My Budget", want: "my-budget"},
+		{name: "path separators", input: `Taxes/2024: "Joint"`, want: "taxes-2024-joint"},
+		{name: "backslash", input: `Home\Office`, want: "home-office"},
+		{name: "reserved characters", input: `a<b>c|d?e*f`, want: "a-b-c-d-e-f"},
+		{name: "runs of separators", input: "  --Budget -- 2025--  ", want: "budget-2025"},
+		{name: "accents", input: "Café Crème Brûlée", want: "cafe-creme-brulee"},
+		{name: "ligature", input: "ﬁnances", want: "finances"},
+		{name: "full-width forms", input: "ＢＵＤＧＥＴ　２０２４", want: "budget-2024"},
+		{name: "non-Latin script", input: "家計簿 2025", want: "家計簿-2025"},
+		{name: "non-Latin marks kept", input: "ガイド", want: "ガイド"},
+		{name: "emoji between words", input: "Fun 🎉 Money", want: "fun-money"},
+		{name: "emoji only falls back to ID", input: "💰💸", fallback: budgetID, want: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a01"},
+		{name: "empty falls back to ID", input: "", fallback: budgetID, want: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a01"},
+		{name: "no fallback", input: "🎉", want: "budget"},
+		{name: "Windows reserved name", input: "CON", want: "con-budget"},
+		{name: "Windows reserved name with punctuation", input: "lpt1!", want: "lpt1-budget"},
+		{name: "reserved name as prefix", input: "Console", want: "console"},
+		{name: "long name", input: strings.Repeat("a", 100), want: strings.Repeat("a", maxSlugLength)},
+		{name: "long multibyte name", input: strings.Repeat("日", 30), want: strings.Repeat("日", 21)},
+		{name: "no trailing dash after truncation", input: strings.Repeat("a", 63) + " b", want: strings.Repeat("a", 63)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slugify(tt.input, tt.fallback); got != tt.want {
+				t.Errorf("slugify(%q, %q) = %q, want %q", tt.input, tt.fallback, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateSlug(t *testing.T) {
+	tests := []struct {
+		name     string
+		slug     string
+		maxBytes int
+		want     string
+	}{
+		{name: "short", slug: "budget", maxBytes: 10, want: "budget"},
+		{name: "exact", slug: "budget", maxBytes: 6, want: "budget"},
+		{name: "ASCII", slug: "my-budget", maxBytes: 5, want: "my-bu"},
+		{name: "trailing dash", slug: "my-budget", maxBytes: 3, want: "my"},
+		{name: "several trailing dashes", slug: "a--b", maxBytes: 3, want: "a"},
+		{name: "inside a character", slug: "日本語", maxBytes: 4, want: "日"},
+		{name: "character boundary", slug: "日本語", maxBytes: 6, want: "日本"},
+		{name: "accented", slug: "cafés", maxBytes: 4, want: "caf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateSlug(tt.slug, tt.maxBytes); got != tt.want {
+				t.Errorf("truncateSlug(%q, %d) = %q, want %q", tt.slug, tt.maxBytes, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,587 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver, so builds need no cgo
+)
+
+// sqliteSchemaVersion is stored as the database's user_version, so a later schema
+// can recognize databases written with this one.
+const sqliteSchemaVersion = 1
+
+// sqliteBusyTimeout is how long an export waits for another export writing to the
+// same database, e.g. with --all, in milliseconds.
+const sqliteBusyTimeout = 30000
+
+// sqliteRow is an object of the budget JSON that becomes a table row. Values are
+// kept as raw JSON until they are stored, so no field is lost or rounded.
+type sqliteRow map[string]jsontext.Value
+
+// sqliteDocument is a budget download as read for the database.
+type sqliteDocument struct {
+	Data struct {
+		Budget          sqliteRow `json:"budget"`
+		ServerKnowledge int64     `json:"server_knowledge"`
+	} `json:"data"`
+}
+
+// sqliteColumn is a column of a table, read from the JSON member of the same name
+// unless field names another. A field may name a member of a nested object, such
+// as "currency_format.iso_code".
+type sqliteColumn struct {
+	name  string
+	decl  string // Type and constraints
+	field string
+}
+
+// sqliteTable is a table of the database. Every table but budgets belongs to a
+// budget, so it has a budget_id column that is not listed in columns.
+type sqliteTable struct {
+	name    string
+	source  string // Member of the budget JSON holding the rows
+	key     []string
+	columns []sqliteColumn
+	indexes []string // Indexed columns, comma-separated for multi-column indexes
+}
+
+// References between tables. Exports do not enforce them, so a reference YNAB
+// left dangling cannot fail a backup; PRAGMA foreign_key_check lists any. With
+// enforcement turned on, they are only checked when a transaction commits, since
+// accounts and payees refer to each other.
+const (
+	refBudget               = "REFERENCES budgets(id) DEFERRABLE INITIALLY DEFERRED"
+	refAccount              = "REFERENCES accounts(id) DEFERRABLE INITIALLY DEFERRED"
+	refCategoryGroup        = "REFERENCES category_groups(id) DEFERRABLE INITIALLY DEFERRED"
+	refCategory             = "REFERENCES categories(id) DEFERRABLE INITIALLY DEFERRED"
+	refPayee                = "REFERENCES payees(id) DEFERRABLE INITIALLY DEFERRED"
+	refTransaction          = "REFERENCES transactions(id) DEFERRABLE INITIALLY DEFERRED"
+	refScheduledTransaction = "REFERENCES scheduled_transactions(id) DEFERRABLE INITIALLY DEFERRED"
+)
+
+// Column declarations shared by many tables. Amounts are in milliunits and
+// booleans are 0 or 1.
+const (
+	declID       = "TEXT NOT NULL"
+	declText     = "TEXT"
+	declAmount   = "INTEGER NOT NULL"
+	declInteger  = "INTEGER"
+	declBool     = "INTEGER NOT NULL"
+	declOptional = "INTEGER" // Nullable boolean
+)
+
+// sqliteTables lists the tables of the database, parents before children.
+var sqliteTables = []sqliteTable{
+	{
+		name: "budgets",
+		key:  []string{"id"},
+		columns: []sqliteColumn{
+			{"id", declID, ""},
+			{"name", "TEXT NOT NULL", ""},
+			{"last_modified_on", declText, ""},
+			{"first_month", declText, ""},
+			{"last_month", declText, ""},
+			{"date_format", declText, "date_format.format"},
+			{"currency_iso_code", declText, "currency_format.iso_code"},
+			{"currency_example_format", declText, "currency_format.example_format"},
+			{"currency_decimal_digits", declInteger, "currency_format.decimal_digits"},
+			{"currency_decimal_separator", declText, "currency_format.decimal_separator"},
+			{"currency_symbol_first", declOptional, "currency_format.symbol_first"},
+			{"currency_group_separator", declText, "currency_format.group_separator"},
+			{"currency_symbol", declText, "currency_format.currency_symbol"},
+			{"currency_display_symbol", declOptional, "currency_format.display_symbol"},
+		},
+	},
+	{
+		name:   "accounts",
+		source: "accounts",
+		key:    []string{"id"},
+		columns: []sqliteColumn{
+			{"id", declID, ""},
+			{"name", "TEXT NOT NULL", ""},
+			{"type", "TEXT NOT NULL", ""},
+			{"on_budget", declBool, ""},
+			{"closed", declBool, ""},
+			{"note", declText, ""},
+			{"balance", declAmount, ""},
+			{"cleared_balance", declAmount, ""},
+			{"uncleared_balance", declAmount, ""},
+			{"transfer_payee_id", declText + " " + refPayee, ""},
+			{"direct_import_linked", declOptional, ""},
+			{"direct_import_in_error", declOptional, ""},
+			{"last_reconciled_at", declText, ""},
+			{"debt_original_balance", declInteger, ""},
+			{"debt_interest_rates", declText, ""}, // JSON objects of rates by month
+			{"debt_minimum_payments", declText, ""},
+			{"debt_escrow_amounts", declText, ""},
+			{"deleted", declBool, ""},
+		},
+		indexes: []string{"budget_id"},
+	},
+	{
+		name:   "category_groups",
+		source: "category_groups",
+		key:    []string{"id"},
+		columns: []sqliteColumn{
+			{"id", declID, ""},
+			{"name", "TEXT NOT NULL", ""},
+			{"hidden", declBool, ""},
+			{"deleted", declBool, ""},
+		},
+		indexes: []string{"budget_id"},
+	},
+	{
+		name:   "categories",
+		source: "categories",
+		key:    []string{"id"},
+		columns: append([]sqliteColumn{
+			{"id", declID, ""},
+			{"category_group_id", "TEXT NOT NULL " + refCategoryGroup, ""},
+			{"name", "TEXT NOT NULL", ""},
+			{"hidden", declBool, ""},
+			{"original_category_group_id", declText, ""},
+			{"note", declText, ""},
+		}, categoryAmountColumns...),
+		indexes: []string{"budget_id", "category_group_id"},
+	},
+	{
+		name:   "payees",
+		source: "payees",
+		key:    []string{"id"},
+		columns: []sqliteColumn{
+			{"id", declID, ""},
+			{"name", "TEXT NOT NULL", ""},
+			{"transfer_account_id", declText + " " + refAccount, ""},
+			{"deleted", declBool, ""},
+		},
+		indexes: []string{"budget_id", "name"},
+	},
+	{
+		name:   "payee_locations",
+		source: "payee_locations",
+		key:    []string{"id"},
+		columns: []sqliteColumn{
+			{"id", declID, ""},
+			{"payee_id", "TEXT NOT NULL " + refPayee, ""},
+			{"latitude", declText, ""},
+			{"longitude", declText, ""},
+			{"deleted", declBool, ""},
+		},
+		indexes: []string{"budget_id", "payee_id"},
+	},
+	{
+		name:   "months",
+		source: "months",
+		key:    []string{"budget_id", "month"},
+		columns: []sqliteColumn{
+			{"month", "TEXT NOT NULL", ""},
+			{"note", declText, ""},
+			{"income", declAmount, ""},
+			{"budgeted", declAmount, ""},
+			{"activity", declAmount, ""},
+			{"to_be_budgeted", declAmount, ""},
+			{"age_of_money", declInteger, ""},
+			{"deleted", declBool, ""},
+		},
+	},
+	{
+		// Rows come from the categories of each month, see sqliteRows
+		name: "month_categories",
+		key:  []string{"budget_id", "month", "category_id"},
+		columns: append([]sqliteColumn{
+			{"month", "TEXT NOT NULL", ""},
+			{"category_id", "TEXT NOT NULL " + refCategory, "id"},
+		}, categoryAmountColumns...),
+		indexes: []string{"category_id"},
+	},
+	{
+		name:   "transactions",
+		source: "transactions",
+		key:    []string{"id"},
+		columns: []sqliteColumn{
+			{"id", declID, ""},
+			{"date", "TEXT NOT NULL", ""},
+			{"account_id", "TEXT NOT NULL " + refAccount, ""},
+			{"payee_id", declText + " " + refPayee, ""},
+			{"category_id", declText + " " + refCategory, ""},
+			{"transfer_account_id", declText + " " + refAccount, ""},
+			{"transfer_transaction_id", declText, ""}, // A transaction or subtransaction
+			{"matched_transaction_id", declText, ""},
+			{"amount", declAmount, ""},
+			{"memo", declText, ""},
+			{"cleared", "TEXT NOT NULL", ""},
+			{"approved", declBool, ""},
+			{"flag_color", declText, ""},
+			{"flag_name", declText, ""},
+			{"import_id", declText, ""},
+			{"import_payee_name", declText, ""},
+			{"import_payee_name_original", declText, ""},
+			{"debt_transaction_type", declText, ""},
+			{"deleted", declBool, ""},
+		},
+		indexes: []string{"budget_id, date", "account_id, date", "payee_id", "category_id"},
+	},
+	{
+		name:   "subtransactions",
+		source: "subtransactions",
+		key:    []string{"id"},
+		columns: []sqliteColumn{
+			{"id", declID, ""},
+			{"transaction_id", "TEXT NOT NULL " + refTransaction, ""},
+			{"payee_id", declText + " " + refPayee, ""},
+			{"category_id", declText + " " + refCategory, ""},
+			{"transfer_account_id", declText + " " + refAccount, ""},
+			{"transfer_transaction_id", declText, ""},
+			{"amount", declAmount, ""},
+			{"memo", declText, ""},
+			{"deleted", declBool, ""},
+		},
+		indexes: []string{"budget_id", "transaction_id", "category_id"},
+	},
+	{
+		name:   "scheduled_transactions",
+		source: "scheduled_transactions",
+		key:    []string{"id"},
+		columns: []sqliteColumn{
+			{"id", declID, ""},
+			{"date_first", "TEXT NOT NULL", ""},
+			{"date_next", "TEXT NOT NULL", ""},
+			{"frequency", "TEXT NOT NULL", ""},
+			{"account_id", "TEXT NOT NULL " + refAccount, ""},
+			{"payee_id", declText + " " + refPayee, ""},
+			{"category_id", declText + " " + refCategory, ""},
+			{"transfer_account_id", declText + " " + refAccount, ""},
+			{"amount", declAmount, ""},
+			{"memo", declText, ""},
+			{"flag_color", declText, ""},
+			{"flag_name", declText, ""},
+			{"deleted", declBool, ""},
+		},
+		indexes: []string{"budget_id, date_next", "account_id"},
+	},
+	{
+		name:   "scheduled_subtransactions",
+		source: "scheduled_subtransactions",
+		key:    []string{"id"},
+		columns: []sqliteColumn{
+			{"id", declID, ""},
+			{"scheduled_transaction_id", "TEXT NOT NULL " + refScheduledTransaction, ""},
+			{"payee_id", declText + " " + refPayee, ""},
+			{"category_id", declText + " " + refCategory, ""},
+			{"transfer_account_id", declText + " " + refAccount, ""},
+			{"amount", declAmount, ""},
+			{"memo", declText, ""},
+			{"deleted", declBool, ""},
+		},
+		indexes: []string{"budget_id", "scheduled_transaction_id"},
+	},
+}
+
+// categoryAmountColumns are the budgeted amounts and goal of a category, which
+// months have for every category as well.
+var categoryAmountColumns = []sqliteColumn{
+	{"budgeted", declAmount, ""},
+	{"activity", declAmount, ""},
+	{"balance", declAmount, ""},
+	{"goal_type", declText, ""},
+	{"goal_needs_whole_amount", declOptional, ""},
+	{"goal_day", declInteger, ""},
+	{"goal_cadence", declInteger, ""},
+	{"goal_cadence_frequency", declInteger, ""},
+	{"goal_creation_month", declText, ""},
+	{"goal_target", declInteger, ""},
+	{"goal_target_month", declText, ""},
+	{"goal_percentage_complete", declInteger, ""},
+	{"goal_months_to_budget", declInteger, ""},
+	{"goal_under_funded", declInteger, ""},
+	{"goal_overall_funded", declInteger, ""},
+	{"goal_overall_left", declInteger, ""},
+	{"goal_snoozed_at", declText, ""},
+	{"deleted", declBool, ""},
+}
+
+// sqliteMetadataSchema records when each budget was last exported to the
+// database, and the server knowledge of that export.
+const sqliteMetadataSchema = `CREATE TABLE IF NOT EXISTS metadata (
+	budget_id TEXT NOT NULL PRIMARY KEY ` + refBudget + `,
+	server_knowledge INTEGER NOT NULL,
+	exported_at TEXT NOT NULL,
+	exporter_version TEXT NOT NULL
+)`
+
+// schema returns the statements creating the table and its indexes.
+func (t sqliteTable) schema() []string {
+	var defs []string
+	if t.name != "budgets" {
+		defs = append(defs, "budget_id TEXT NOT NULL "+refBudget)
+	}
+	for _, c := range t.columns {
+		defs = append(defs, c.name+" "+c.decl)
+	}
+	defs = append(defs, "PRIMARY KEY ("+strings.Join(t.key, ", ")+")")
+	if t.name == "month_categories" {
+		defs = append(defs, "FOREIGN KEY (budget_id, month) REFERENCES months(budget_id, month) DEFERRABLE INITIALLY DEFERRED")
+	}
+
+	statements := []string{"CREATE TABLE IF NOT EXISTS " + t.name + " (\n\t" + strings.Join(defs, ",\n\t") + "\n)"}
+	for _, columns := range t.indexes {
+		name := t.name + "_" + strings.ReplaceAll(columns, ", ", "_")
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", name, t.name, columns))
+	}
+	return statements
+}
+
+// upsert returns the statement that inserts a row into the table, or updates the
+// row with the same key if there is one.
+func (t sqliteTable) upsert() string {
+	var names, params, updates []string
+	if t.name != "budgets" {
+		names, params = append(names, "budget_id"), append(params, "?")
+	}
+	for _, c := range t.columns {
+		names, params = append(names, c.name), append(params, "?")
+		if !slices.Contains(t.key, c.name) {
+			updates = append(updates, c.name+" = excluded."+c.name)
+		}
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		t.name, strings.Join(names, ", "), strings.Join(params, ", "), strings.Join(t.key, ", "), strings.Join(updates, ", "))
+}
+
+// sqliteDSN returns a file: URI for the database at path, escaped so the driver
+// doesn't take a ? in the path for the start of the options.
+func sqliteDSN(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err //nolint:wrapcheck // Wrapped by the caller
+	}
+	// A Windows path needs a leading slash to be the URI's path, e.g. /C:/budgets
+	uriPath := filepath.ToSlash(abs)
+	if !strings.HasPrefix(uriPath, "/") {
+		uriPath = "/" + uriPath
+	}
+	dsn := url.URL{
+		Scheme:   "file",
+		Path:     uriPath,
+		RawQuery: "_txlock=immediate&_pragma=busy_timeout(" + strconv.Itoa(sqliteBusyTimeout) + ")",
+	}
+	return dsn.String(), nil
+}
+
+// writeDatabase stores a budget downloaded to raw in the SQLite database at path,
+// creating the database if needed. Rows already in the database are updated in
+// place, so exporting a budget to the same database again adds no duplicates.
+func (o exportOptions) writeDatabase(ctx context.Context, raw io.ReadSeeker, path string) (string, error) {
+	if _, err := raw.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to read budget: %w", err)
+	}
+	var doc sqliteDocument
+	if err := json.UnmarshalRead(raw, &doc); err != nil {
+		return "", fmt.Errorf("failed to parse budget: %w", err)
+	}
+
+	// Create the file first, so the database is only readable by the user like other exports
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	switch {
+	case err == nil:
+		if err := f.Close(); err != nil {
+			return "", fmt.Errorf("%w: %w", errWriteExport, err)
+		}
+	case errors.Is(err, fs.ErrExist) && o.overwrite == overwriteNoClobber:
+		return "", fmt.Errorf("%w: %w: %s", errWriteExport, errFileExists, path)
+	case !errors.Is(err, fs.ErrExist):
+		return "", fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+
+	dsn, err := sqliteDSN(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	defer db.Close() //nolint:errcheck // Closed below on success
+
+	if err := storeBudget(ctx, db, doc, time.Now()); err != nil {
+		return "", fmt.Errorf("%w: %s: %w", errWriteExport, path, err)
+	}
+	if err := db.Close(); err != nil {
+		return "", fmt.Errorf("%w: %w", errWriteExport, err)
+	}
+	return path, nil
+}
+
+// storeBudget creates the schema if needed and upserts every row of the budget in
+// a single transaction, so a failed export leaves the database as it was.
+func storeBudget(ctx context.Context, db *sql.DB, doc sqliteDocument, now time.Time) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // No-op once committed
+
+	var schemaVersion int
+	if err := tx.QueryRowContext(ctx, "PRAGMA user_version").Scan(&schemaVersion); err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	switch schemaVersion {
+	case 0:
+		if _, err := tx.ExecContext(ctx, "PRAGMA user_version = "+strconv.Itoa(sqliteSchemaVersion)); err != nil {
+			return fmt.Errorf("failed to create schema: %w", err)
+		}
+	case sqliteSchemaVersion:
+	default:
+		return fmt.Errorf("database has schema version %d, but this version of ynab-export writes version %d",
+			schemaVersion, sqliteSchemaVersion)
+	}
+
+	schema := []string{sqliteMetadataSchema}
+	for _, t := range sqliteTables {
+		schema = append(schema, t.schema()...)
+	}
+	for _, statement := range schema {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create schema: %w", err)
+		}
+	}
+
+	budget := doc.Data.Budget
+	budgetID := budget["id"]
+	rows, err := sqliteRows(budget)
+	if err != nil {
+		return err
+	}
+	for _, t := range sqliteTables {
+		if err := upsertRows(ctx, tx, t, budgetID, rows[t.name]); err != nil {
+			return fmt.Errorf("failed to write %s: %w", t.name, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO metadata (budget_id, server_knowledge, exported_at, exporter_version)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (budget_id) DO UPDATE SET server_knowledge = excluded.server_knowledge,
+			exported_at = excluded.exported_at, exporter_version = excluded.exporter_version`,
+		sqliteValue(budgetID), doc.Data.ServerKnowledge, now.UTC().Format(time.RFC3339), version)
+	if err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// sqliteRows collects the rows of each table from the budget JSON.
+func sqliteRows(budget sqliteRow) (map[string][]sqliteRow, error) {
+	rows := map[string][]sqliteRow{"budgets": {budget}}
+	for _, t := range sqliteTables {
+		if t.source == "" || len(budget[t.source]) == 0 {
+			continue
+		}
+		var list []sqliteRow
+		if err := json.Unmarshal(budget[t.source], &list); err != nil {
+			return nil, fmt.Errorf("failed to parse budget %s: %w", t.source, err)
+		}
+		rows[t.name] = list
+	}
+
+	// Each month has the amounts of every category in that month
+	for _, month := range rows["months"] {
+		if len(month["categories"]) == 0 {
+			continue
+		}
+		var categories []sqliteRow
+		if err := json.Unmarshal(month["categories"], &categories); err != nil {
+			return nil, fmt.Errorf("failed to parse budget months: %w", err)
+		}
+		for _, category := range categories {
+			category["month"] = month["month"]
+			rows["month_categories"] = append(rows["month_categories"], category)
+		}
+	}
+	return rows, nil
+}
+
+// upsertRows writes the rows of one table.
+func upsertRows(ctx context.Context, tx *sql.Tx, t sqliteTable, budgetID jsontext.Value, rows []sqliteRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	stmt, err := tx.PrepareContext(ctx, t.upsert())
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close() //nolint:errcheck // Closed with the transaction anyway
+
+	args := make([]any, 0, len(t.columns)+1)
+	for _, row := range rows {
+		args = args[:0]
+		if t.name != "budgets" {
+			args = append(args, sqliteValue(budgetID))
+		}
+		for _, c := range t.columns {
+			args = append(args, sqliteValue(row.get(cmp.Or(c.field, c.name))))
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("failed to write row: %w", err)
+		}
+	}
+	return nil
+}
+
+// get returns the value of a member, following dots into nested objects. Missing
+// members have no value.
+func (r sqliteRow) get(field string) jsontext.Value {
+	name, rest, nested := strings.Cut(field, ".")
+	if !nested {
+		return r[name]
+	}
+	var obj sqliteRow
+	if err := json.Unmarshal(r[name], &obj); err != nil {
+		return nil
+	}
+	return obj.get(rest)
+}
+
+// sqliteValue converts a JSON value to the value stored in its column. Booleans
+// become 0 or 1, and objects and arrays are stored as JSON text.
+func sqliteValue(v jsontext.Value) any {
+	switch v.Kind() {
+	case 'n', 0: // null, or a missing member
+		return nil
+	case 't':
+		return 1
+	case 'f':
+		return 0
+	case '"':
+		var s string
+		if err := json.Unmarshal(v, &s); err == nil {
+			return s
+		}
+	case '0':
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return n
+		}
+		if f, err := strconv.ParseFloat(string(v), 64); err == nil {
+			return f
+		}
+	}
+	return string(v)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sqliteTestBudget is a budget download with a row in a few of the tables.
+const sqliteTestBudget = `{"data": {"server_knowledge": %d, "budget": {
+	"id": "b1", "name": "Test Budget", "last_modified_on": "2025-01-12T10:00:00Z",
+	"currency_format": {"iso_code": "USD", "decimal_digits": 2, "symbol_first": true},
+	"accounts": [{"id": "a-chk", "name": %q, "type": "checking", "on_budget": true, "closed": false,
+		"balance": 1321990, "cleared_balance": 1334330, "uncleared_balance": -12340, "deleted": false}],
+	"payees": [{"id": "p-grocer", "name": "Grocer", "deleted": %t}],
+	"months": [{"month": "2025-01-01", "income": 2000000, "budgeted": 0, "activity": 0, "to_be_budgeted": 0,
+		"deleted": false, "categories": [{"id": "c-groceries", "category_group_id": "g-food", "name": "Groceries",
+		"hidden": false, "budgeted": 100000, "activity": -45670, "balance": 54330, "deleted": false}]}],
+	"transactions": [%s]
+}}}`
+
+const sqliteTestTransaction = `{"id": "%s", "date": "2025-01-03", "amount": -45670, "memo": "Weekly shop",
+	"cleared": "reconciled", "approved": true, "flag_color": null, "account_id": "a-chk", "payee_id": "p-grocer",
+	"category_id": "c-groceries", "deleted": false}`
+
+func sqliteTestDownload(serverKnowledge int64, accountName string, payeeDeleted bool, transactionIDs ...string) []byte {
+	txns := make([]string, len(transactionIDs))
+	for i, id := range transactionIDs {
+		txns[i] = fmt.Sprintf(sqliteTestTransaction, id)
+	}
+	return fmt.Appendf(nil, sqliteTestBudget, serverKnowledge, accountName, payeeDeleted, strings.Join(txns, ","))
+}
+
+func TestWriteDatabaseUpserts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ynab.sqlite")
+	o := exportOptions{format: formatSQLite}
+	ctx := context.Background()
+
+	if _, err := o.writeDatabase(ctx, bytes.NewReader(sqliteTestDownload(10, "Checking", false, "t-1")), path); err != nil {
+		t.Fatalf("first writeDatabase() error = %v", err)
+	}
+	// A later export renames the account, deletes the payee and adds a transaction
+	if _, err := o.writeDatabase(ctx, bytes.NewReader(sqliteTestDownload(20, "Main Checking", true, "t-1", "t-2")), path); err != nil {
+		t.Fatalf("second writeDatabase() error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	counts := []struct {
+		table string
+		want  int
+	}{
+		{table: "budgets", want: 1},
+		{table: "accounts", want: 1},
+		{table: "payees", want: 1},
+		{table: "months", want: 1},
+		{table: "month_categories", want: 1},
+		{table: "transactions", want: 2},
+		{table: "subtransactions", want: 0},
+		{table: "metadata", want: 1},
+	}
+	for _, c := range counts {
+		var n int
+		if err := db.QueryRow("SELECT count(*) FROM " + c.table).Scan(&n); err != nil {
+			t.Fatalf("failed to count %s: %v", c.table, err)
+		}
+		if n != c.want {
+			t.Errorf("%s has %d rows, want %d", c.table, n, c.want)
+		}
+	}
+
+	values := []struct {
+		query string
+		want  any
+	}{
+		{query: "SELECT name FROM accounts WHERE id = 'a-chk'", want: "Main Checking"},
+		{query: "SELECT budget_id FROM accounts WHERE id = 'a-chk'", want: "b1"},
+		{query: "SELECT balance FROM accounts WHERE id = 'a-chk'", want: int64(1321990)},
+		{query: "SELECT on_budget FROM accounts WHERE id = 'a-chk'", want: int64(1)},
+		{query: "SELECT deleted FROM payees WHERE id = 'p-grocer'", want: int64(1)},
+		{query: "SELECT currency_iso_code FROM budgets WHERE id = 'b1'", want: "USD"},
+		{query: "SELECT flag_color FROM transactions WHERE id = 't-1'", want: nil},
+		{query: "SELECT balance FROM month_categories WHERE month = '2025-01-01' AND category_id = 'c-groceries'",
+			want: int64(54330)},
+		{query: "SELECT server_knowledge FROM metadata WHERE budget_id = 'b1'", want: int64(20)},
+	}
+	for _, v := range values {
+		var got any
+		if err := db.QueryRow(v.query).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", v.query, err)
+		}
+		if got != v.want {
+			t.Errorf("%s = %#v, want %#v", v.query, got, v.want)
+		}
+	}
+}
+
+func TestWriteDatabaseNoClobber(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ynab.sqlite")
+	ctx := context.Background()
+	if _, err := (exportOptions{}).writeDatabase(ctx, bytes.NewReader(sqliteTestDownload(1, "Checking", false)), path); err != nil {
+		t.Fatalf("writeDatabase() error = %v", err)
+	}
+
+	o := exportOptions{overwrite: overwriteNoClobber}
+	_, err := o.writeDatabase(ctx, bytes.NewReader(sqliteTestDownload(2, "Checking", false)), path)
+	if !errors.Is(err, errFileExists) {
+		t.Errorf("writeDatabase() error = %v, want %v", err, errFileExists)
+	}
+}
+
+func TestWriteDatabaseQuestionMarkPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "what?dir")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "ynab.sqlite")
+	if _, err := (exportOptions{}).writeDatabase(context.Background(), bytes.NewReader(sqliteTestDownload(1, "Checking", false, "t-1")), path); err != nil {
+		t.Fatalf("writeDatabase() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "what?dir" {
+		t.Errorf("database written outside %s", dir)
+	}
+
+	dsn, err := sqliteDSN(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM transactions").Scan(&n); err != nil {
+		t.Fatalf("failed to count transactions: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("transactions has %d rows, want 1", n)
+	}
+}
+
+func TestStoreBudgetSchemaVersion(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "ynab.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("PRAGMA user_version = 99"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = storeBudget(context.Background(), db, sqliteDocument{}, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "schema version 99") {
+		t.Errorf("storeBudget() error = %v, want a schema version error", err)
+	}
+}
+
+func TestSQLiteTableUpsert(t *testing.T) {
+	tests := []struct {
+		name  string
+		table sqliteTable
+		want  string
+	}{
+		{
+			name: "budgets",
+			table: sqliteTable{name: "budgets", key: []string{"id"},
+				columns: []sqliteColumn{{"id", declID, ""}, {"name", declText, ""}}},
+			want: "INSERT INTO budgets (id, name) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET name = excluded.name",
+		},
+		{
+			name: "budget child",
+			table: sqliteTable{name: "payees", key: []string{"id"},
+				columns: []sqliteColumn{{"id", declID, ""}, {"name", declText, ""}, {"deleted", declBool, ""}}},
+			want: "INSERT INTO payees (budget_id, id, name, deleted) VALUES (?, ?, ?, ?) " +
+				"ON CONFLICT (id) DO UPDATE SET name = excluded.name, deleted = excluded.deleted",
+		},
+		{
+			name: "composite key",
+			table: sqliteTable{name: "months", key: []string{"budget_id", "month"},
+				columns: []sqliteColumn{{"month", declID, ""}, {"income", declAmount, ""}}},
+			want: "INSERT INTO months (budget_id, month, income) VALUES (?, ?, ?) " +
+				"ON CONFLICT (budget_id, month) DO UPDATE SET income = excluded.income",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.table.upsert(); got != tt.want {
+				t.Errorf("upsert() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  any
+	}{
+		{value: "", want: nil},
+		{value: "null", want: nil},
+		{value: "true", want: 1},
+		{value: "false", want: 0},
+		{value: `"Grocer"`, want: "Grocer"},
+		{value: `"café"`, want: "café"},
+		{value: "-45670", want: int64(-45670)},
+		{value: "12.5", want: 12.5},
+		{value: `{"2025-01-01":4500}`, want: `{"2025-01-01":4500}`},
+		{value: "[1,2]", want: "[1,2]"},
+	}
+	for _, tt := range tests {
+		if got := sqliteValue([]byte(tt.value)); got != tt.want {
+			t.Errorf("sqliteValue(%s) = %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSQLiteRowGet(t *testing.T) {
+	row := sqliteRow{
+		"name":            []byte(`"Test Budget"`),
+		"currency_format": []byte(`{"iso_code": "USD", "decimal_digits": 2}`),
+	}
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{field: "name", want: `"Test Budget"`},
+		{field: "currency_format.iso_code", want: `"USD"`},
+		{field: "currency_format.decimal_digits", want: "2"},
+		{field: "currency_format.symbol", want: ""},
+		{field: "missing.field", want: ""},
+	}
+	for _, tt := range tests {
+		if got := string(row.get(tt.field)); got != tt.want {
+			t.Errorf("get(%q) = %s, want %s", tt.field, got, tt.want)
+		}
+	}
+}
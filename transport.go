@@ -0,0 +1,113 @@
+package main
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+)
+
+// errTLSVerification marks requests that failed because the API's certificate could not be verified.
+var errTLSVerification = errors.New("TLS certificate verification failed")
+
+// proxySchemes lists the proxy URL schemes supported by net/http.
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// newTransport builds the HTTP transport shared by all requests from the TLS and
+// proxy settings. Without a proxy, the standard HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY environment variables apply.
+func newTransport(opts clientOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // Always an *http.Transport
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.caCert != "" {
+		// Trust the bundle in addition to the system roots, so only the proxy's CA is needed
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(opts.caCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in CA certificate file %s", opts.caCert)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	switch {
+	case opts.clientCert != "":
+		// The key may be in the same file as the certificate
+		keyFile := cmp.Or(opts.clientKey, opts.clientCert)
+		cert, err := tls.LoadX509KeyPair(opts.clientCert, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	case opts.clientKey != "":
+		return nil, errors.New("--client-key needs --client-cert")
+	}
+
+	if opts.proxy != "" {
+		proxyURL, err := url.Parse(opts.proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		if !slices.Contains(proxySchemes, proxyURL.Scheme) || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q: use a URL such as http://proxy.example.com:8080", opts.proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return transport, nil
+}
+
+// diagnoseTLS explains a failure to verify the API's certificate, which usually
+// means a proxy is inspecting TLS traffic, or the server rejecting the client
+// certificate. Other errors are returned unchanged.
+func diagnoseTLS(err error) error {
+	// crypto/tls reports alerts sent by the server as "remote error" operations
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "remote error" {
+		return fmt.Errorf("%w: the server rejected the connection (%v). "+
+			"If it requires a client certificate, check --client-cert and --client-key: %w",
+			errTLSVerification, opErr.Err, err)
+	}
+
+	var verifyErr *tls.CertificateVerificationError
+	if !errors.As(err, &verifyErr) {
+		return err
+	}
+
+	presented := ""
+	if len(verifyErr.UnverifiedCertificates) > 0 {
+		cert := verifyErr.UnverifiedCertificates[0]
+		presented = fmt.Sprintf(" (the server presented a certificate for %q issued by %q)",
+			cert.Subject.CommonName, cert.Issuer.String())
+	}
+
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("%w: the certificate is signed by an unknown authority%s. "+
+			"If a proxy inspects your TLS traffic, trust its CA certificate with --ca-cert: %w",
+			errTLSVerification, presented, err)
+	case errors.As(err, &hostnameErr):
+		return fmt.Errorf("%w: the certificate is not valid for %s%s. "+
+			"Check --proxy and the HTTPS_PROXY environment variable: %w",
+			errTLSVerification, hostnameErr.Host, presented, err)
+	case errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired:
+		return fmt.Errorf("%w: the certificate has expired or is not valid yet%s. "+
+			"Check that your system clock is correct: %w", errTLSVerification, presented, err)
+	}
+	return fmt.Errorf("%w%s: %w", errTLSVerification, presented, err)
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -16,6 +22,9 @@ import (
 const (
 	// YNAB API tokens are 43 characters long.
 	ynabTokenLength = 43
+
+	// progressInterval is how often the download progress is redrawn.
+	progressInterval = 100 * time.Millisecond
 )
 
 var (
@@ -28,6 +37,12 @@ var (
 	fieldStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("46")) // Green for field names like nushell
 )
 
+// Additional key bindings shown in the budget selection help.
+var (
+	toggleBudgetKey = key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "select"))
+	exportAllKey    = key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "export all"))
+)
+
 type state int
 
 const (
@@ -35,6 +50,7 @@ const (
 	stateToken
 	stateFetchingBudgets
 	stateBudgetSelect
+	stateRateLimitWarning
 	stateExporting
 	stateDone
 	stateError
@@ -60,6 +76,31 @@ func humanizeFileSize(bytes int64) string {
 	}
 }
 
+// formatExportSize describes the size of an export, including the compressed size if any.
+func formatExportSize(summary budgetSummary) string {
+	if summary.Compression == "" {
+		return humanizeFileSize(summary.FileSize)
+	}
+	return fmt.Sprintf("%s raw, %s %s", humanizeFileSize(summary.FileSize),
+		humanizeFileSize(summary.CompressedSize), summary.Compression)
+}
+
+// formatExportType describes whether an export is a full export or a delta.
+func formatExportType(summary budgetSummary) string {
+	if summary.DeltaSince == 0 {
+		return "full (server knowledge " + strconv.FormatInt(summary.ServerKnowledge, 10) + ")"
+	}
+	return fmt.Sprintf("delta (server knowledge %d → %d)", summary.DeltaSince, summary.ServerKnowledge)
+}
+
+// formatEncryption describes whether an export is encrypted, and how.
+func formatEncryption(summary budgetSummary) string {
+	if summary.Encryption == "" {
+		return "no"
+	}
+	return "yes, " + summary.Encryption
+}
+
 // formatMonthYear converts a date string (YYYY-MM-DD) to "Mon YYYY" format.
 func formatMonthYear(dateStr string) string {
 	t, err := time.Parse(time.DateOnly, dateStr)
@@ -69,20 +110,12 @@ func formatMonthYear(dateStr string) string {
 	return t.Format("Jan 2006")
 }
 
-// createBudgetTable creates a Nushell-style table from JSON data.budget object.
-func createBudgetTable(jsonData []byte) string {
-	// Extract keys and values in their original order
-	keys, budget, err := extractBudgetKeysAndValues(jsonData)
-	if err != nil {
-		return fmt.Sprintf("Error extracting budget data: %v", err)
-	}
-
+// createBudgetTable creates a Nushell-style table from the described members of data.budget.
+func createBudgetTable(fields OrderedObject[string]) string {
 	// Create rows for the table
-	rows := make([][]string, 0, len(keys))
-	for _, key := range keys {
-		value := budget[key]
-		inspected := inspectJSONValue(value)
-		rows = append(rows, []string{key, inspected})
+	rows := make([][]string, 0, len(fields))
+	for _, field := range fields {
+		rows = append(rows, []string{field.Name, field.Value})
 	}
 
 	// Create lipgloss table with Nushell-style borders
@@ -105,45 +138,82 @@ type model struct {
 	budgetList         list.Model
 	err                error
 	selectedBudget     budget
+	exportOpts         exportOptions
+	api                clientOptions
+	client             *client // Set once the token is validated
 	token              string
-	exportPath         string
+	exportPaths        []string
 	tokenValidationErr string
 	budgets            []budget
 	tokenInput         textinput.Model
 	budgetTable        string
+	budgetQuery        string
+	summaryPath        string
+	exports            []budgetExport
 	summary            budgetSummary
 	state              state
+	concurrency        int
+	batchSize          int
 	tokenLengthValid   bool
 	tokenSource        TokenSource
+	exportAll          bool
+	pendingBudgets     []budget // Export waiting for the rate limit warning to be answered
+	pendingBatch       bool
+	rateLimitConfirmed bool
+	download           *transferProgress  // Progress of the export in flight
+	cancelExport       context.CancelFunc // Cancels the export in flight
+	exportSeq          int                // Identifies the export in flight, so results of canceled ones are ignored
+	quitting           bool               // Quit once the canceled export has cleaned up
+	cancelRequest      context.CancelFunc // Cancels the token validation or budget fetch in flight
+	requestCtx         context.Context    //nolint:containedctx // The context of the validation Init starts
+	requestSeq         int                // Identifies the request in flight, so results of canceled ones are ignored
+	progressBar        progress.Model
 }
 
 type budgetsFetchedMsg struct {
 	err     error
+	seq     int
 	budgets []budget
 }
 
 type exportDoneMsg struct {
-	err      error
-	path     string
-	jsonData []byte
-	summary  budgetSummary
+	err       error
+	seq       int
+	paths     []string
+	structure OrderedObject[string] // Members of data.budget, described for display
+	summary   budgetSummary
+}
+
+type exportAllDoneMsg struct {
+	err         error
+	seq         int
+	summaryPath string
+	exports     []budgetExport
+}
+
+// progressTickMsg redraws the download progress while exporting.
+type progressTickMsg struct{}
+
+func tickProgress() tea.Cmd {
+	return tea.Tick(progressInterval, func(time.Time) tea.Msg { return progressTickMsg{} })
 }
 
 type tokenValidatedMsg struct {
 	err   error
+	seq   int
 	token string
 }
 
-func validateTokenAsync(token string) tea.Cmd {
+func validateTokenAsync(ctx context.Context, token string, opts clientOptions, seq int) tea.Cmd {
 	return func() tea.Msg {
-		if err := validateToken(token); err != nil {
-			return tokenValidatedMsg{err: err}
+		if err := newClient(token, opts).validateToken(ctx); err != nil {
+			return tokenValidatedMsg{err: err, seq: seq}
 		}
-		return tokenValidatedMsg{token: token}
+		return tokenValidatedMsg{token: token, seq: seq}
 	}
 }
 
-func initialModel(token string, source TokenSource) model {
+func initialModel(token string, source TokenSource, opts options) model {
 	ti := textinput.New()
 	ti.Placeholder = "Enter your YNAB API token..."
 	ti.Focus()
@@ -152,26 +222,41 @@ func initialModel(token string, source TokenSource) model {
 	ti.EchoMode = textinput.EchoPassword
 	ti.EchoCharacter = '•'
 
+	bar := progress.New(progress.WithDefaultGradient(), progress.WithWidth(60))
+
 	// If we have a token from flag, env, or cache, validate it
 	if token != "" && source != TokenSourceNone {
-		return model{
-			state:       stateValidatingToken,
+		m := model{
 			token:       token,
 			tokenSource: source,
 			tokenInput:  ti,
+			budgetQuery: opts.budget,
+			exportAll:   opts.all,
+			concurrency: opts.concurrency,
+			exportOpts:  opts.export,
+			api:         opts.api,
+			progressBar: bar,
 		}
+		m, m.requestCtx = m.beginRequest(stateValidatingToken)
+		return m
 	}
 
 	return model{
-		state:      stateToken,
-		tokenInput: ti,
+		state:       stateToken,
+		tokenInput:  ti,
+		budgetQuery: opts.budget,
+		exportAll:   opts.all,
+		concurrency: opts.concurrency,
+		exportOpts:  opts.export,
+		api:         opts.api,
+		progressBar: bar,
 	}
 }
 
 func (m model) Init() tea.Cmd {
 	// If we're starting in validating state, validate the token
 	if m.state == stateValidatingToken {
-		return validateTokenAsync(m.token)
+		return validateTokenAsync(m.requestCtx, m.token, m.api, m.requestSeq)
 	}
 	return textinput.Blink
 }
@@ -180,13 +265,37 @@ func (m model) Init() tea.Cmd {
 func (m model) handleKeyPress(key string) (model, tea.Cmd) {
 	switch key {
 	case "ctrl+c":
-		// Always allow Ctrl+C to quit
+		// Cancel an export in flight and quit once it has removed its partial
+		// download; a second Ctrl+C quits straight away
+		if m.state == stateExporting && !m.quitting {
+			m.cancelExport()
+			m.quitting = true
+			return m, nil
+		}
+		if m.state == stateValidatingToken || m.state == stateFetchingBudgets {
+			m.cancelRequest()
+		}
 		return m, tea.Quit
 	case "q":
 		// Allow 'q' to quit only in done/error states
 		if m.state == stateDone || m.state == stateError {
 			return m, tea.Quit
 		}
+	case " ":
+		// Toggle the highlighted budget, unless the key is part of a filter being typed
+		if m.state == stateBudgetSelect && m.budgetList.FilterState() != list.Filtering {
+			return m.toggleSelectedBudget()
+		}
+	case "a":
+		// Export all budgets, unless the key is part of a filter being typed
+		if m.state == stateBudgetSelect && m.budgetList.FilterState() != list.Filtering {
+			return m.startExportAll(m.budgets)
+		}
+	case "w", "c":
+		// Wait for the rate limit or continue anyway
+		if m.state == stateRateLimitWarning {
+			return m.resumeExport(key == "w")
+		}
 	case "esc":
 		return m.handleEscapeKey()
 	case "enter":
@@ -197,6 +306,28 @@ func (m model) handleKeyPress(key string) (model, tea.Cmd) {
 
 // handleEscapeKey handles Esc key press.
 func (m model) handleEscapeKey() (model, tea.Cmd) {
+	if m.state == stateExporting {
+		// Abort the download and go back to budget selection
+		m.cancelExport()
+		m.download = nil
+		m.state = stateBudgetSelect
+		return m, nil
+	}
+	if m.state == stateValidatingToken || m.state == stateFetchingBudgets {
+		// Abort the request and go back to token entry
+		m.cancelRequest()
+		m.state = stateToken
+		m.token = ""
+		m.tokenSource = TokenSourceNone
+		m.tokenInput.SetValue("")
+		m.tokenInput.Focus()
+		return m, textinput.Blink
+	}
+	if m.state == stateRateLimitWarning {
+		m.pendingBudgets = nil
+		m.state = stateBudgetSelect
+		return m, nil
+	}
 	if m.state == stateBudgetSelect {
 		// If filtering is active, let the list handle Esc to clear filter
 		if m.budgetList.FilterState() == list.Filtering {
@@ -219,16 +350,18 @@ func (m model) handleEnterKey() (model, tea.Cmd) {
 		if strings.TrimSpace(m.tokenInput.Value()) != "" && m.tokenLengthValid {
 			m.token = strings.TrimSpace(m.tokenInput.Value())
 			m.tokenSource = TokenSourceManual
-			m.state = stateValidatingToken
-			return m, validateTokenAsync(m.token)
+			m, ctx := m.beginRequest(stateValidatingToken)
+			return m, validateTokenAsync(ctx, m.token, m.api, m.requestSeq)
 		}
 	case stateBudgetSelect:
+		// Export the marked budgets if there are any, otherwise the highlighted one
+		if marked := m.markedBudgets(); len(marked) > 0 {
+			return m.startExportAll(marked)
+		}
 		if selected, ok := m.budgetList.SelectedItem().(budget); ok {
-			m.selectedBudget = selected
-			m.state = stateExporting
-			return m, func() tea.Msg { return exportBudget(m.token, selected.ID, selected.Name) }
+			return m.startExport(selected)
 		}
-	case stateValidatingToken, stateFetchingBudgets, stateExporting:
+	case stateValidatingToken, stateFetchingBudgets, stateRateLimitWarning, stateExporting:
 		// No action needed for these states
 	case stateDone, stateError:
 		return m, tea.Quit
@@ -236,8 +369,139 @@ func (m model) handleEnterKey() (model, tea.Cmd) {
 	return m, nil
 }
 
+// toggleSelectedBudget marks or unmarks the highlighted budget for export.
+func (m model) toggleSelectedBudget() (model, tea.Cmd) {
+	selected, ok := m.budgetList.SelectedItem().(budget)
+	if !ok {
+		return m, nil
+	}
+	selected.selected = !selected.selected
+	cmd := m.budgetList.SetItem(m.budgetList.GlobalIndex(), selected)
+	// SetItem only returns a command when a filter is applied; return a no-op so the
+	// key isn't passed on to the list
+	if cmd == nil {
+		cmd = func() tea.Msg { return nil }
+	}
+	return m, cmd
+}
+
+// markedBudgets returns the budgets marked for export, in list order.
+func (m model) markedBudgets() []budget {
+	var marked []budget
+	for _, item := range m.budgetList.Items() {
+		if b, ok := item.(budget); ok && b.selected {
+			marked = append(marked, b)
+		}
+	}
+	return marked
+}
+
+// startExport begins exporting the given budget.
+func (m model) startExport(selected budget) (model, tea.Cmd) {
+	if warned, ok := m.warnRateLimit([]budget{selected}, false); ok {
+		return warned, nil
+	}
+
+	m.selectedBudget = selected
+	m, ctx := m.beginExport()
+	c, exportOpts, download, seq := m.client, m.exportOpts, m.download, m.exportSeq
+	return m, tea.Batch(
+		func() tea.Msg {
+			msg := exportBudget(ctx, c, selected.ID, selected.Name, exportOpts, download)
+			msg.seq = seq
+			return msg
+		},
+		tickProgress(),
+	)
+}
+
+// startExportAll begins exporting all the given budgets.
+func (m model) startExportAll(budgets []budget) (model, tea.Cmd) {
+	if m.exportOpts.output != "" {
+		m.err = errors.New("--output can only be used with a single budget, use --output-dir to export several")
+		m.state = stateError
+		return m, nil
+	}
+	if warned, ok := m.warnRateLimit(budgets, true); ok {
+		return warned, nil
+	}
+
+	m.selectedBudget = budget{}
+	m.exports = nil
+	m.batchSize = len(budgets)
+	m, ctx := m.beginExport()
+	c, concurrency, exportOpts, download, seq := m.client, m.concurrency, m.exportOpts, m.download, m.exportSeq
+	return m, tea.Batch(
+		func() tea.Msg {
+			startedAt := time.Now()
+			exports := exportBudgets(ctx, c, budgets, concurrency, exportOpts, download)
+			summaryPath, err := writeRunSummary(exports, startedAt, exportOpts)
+			return exportAllDoneMsg{exports: exports, summaryPath: summaryPath, err: err, seq: seq}
+		},
+		tickProgress(),
+	)
+}
+
+// beginExport switches to the exporting state and returns the context for the
+// new export, which Esc or Ctrl+C cancels.
+func (m model) beginExport() (model, context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelExport = cancel
+	m.exportSeq++
+	m.download = newTransferProgress()
+	m.state = stateExporting
+	return m, ctx
+}
+
+// warnRateLimit switches to the rate limit warning if exporting budgets needs more
+// requests than are left this hour, and reports whether it did.
+func (m model) warnRateLimit(budgets []budget, batch bool) (model, bool) {
+	if m.rateLimitConfirmed || m.client.waitRateLimit || m.client.rateLimit.remaining(time.Now()) >= len(budgets) {
+		return m, false
+	}
+	m.pendingBudgets = budgets
+	m.pendingBatch = batch
+	m.state = stateRateLimitWarning
+	return m, true
+}
+
+// resumeExport starts the export held by the rate limit warning, waiting for the
+// rate limit as needed if wait is true.
+func (m model) resumeExport(wait bool) (model, tea.Cmd) {
+	m.rateLimitConfirmed = true
+	if wait {
+		m.client.waitRateLimit = true
+	}
+	budgets := m.pendingBudgets
+	m.pendingBudgets = nil
+	var cmd tea.Cmd
+	if m.pendingBatch {
+		m, cmd = m.startExportAll(budgets)
+	} else {
+		m, cmd = m.startExport(budgets[0])
+	}
+	// The confirmation only covers this export; later ones are checked again
+	m.rateLimitConfirmed = false
+	return m, cmd
+}
+
+// beginRequest starts a token validation or budget fetch that Esc or Ctrl+C cancels,
+// returning its context.
+func (m model) beginRequest(s state) (model, context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelRequest = cancel
+	m.requestSeq++
+	m.state = s
+	return m, ctx
+}
+
 // handleTokenValidated processes token validation message.
 func (m model) handleTokenValidated(msg tokenValidatedMsg) (model, tea.Cmd) {
+	if msg.seq != m.requestSeq || m.state != stateValidatingToken {
+		// Canceled with Esc
+		return m, nil
+	}
+	m.cancelRequest()
 	if msg.err != nil {
 		// Token validation failed, show token input screen with error
 		// Include the source of the token in the error message
@@ -264,16 +528,25 @@ func (m model) handleTokenValidated(msg tokenValidatedMsg) (model, tea.Cmd) {
 
 	// Token is valid, proceed to fetch budgets
 	m.token = msg.token
+	m.client = newClient(msg.token, m.api)
 	m.tokenLengthValid = false
 	m.tokenValidationErr = ""
-	m.state = stateFetchingBudgets
-	return m, func() tea.Msg { return fetchBudgets(m.token) }
+	m, ctx := m.beginRequest(stateFetchingBudgets)
+	c, seq := m.client, m.requestSeq
+	return m, func() tea.Msg {
+		msg := fetchBudgets(ctx, c)
+		msg.seq = seq
+		return msg
+	}
 }
 
 // handleBudgetsFetched processes budgets fetched message.
-//
-//nolint:unparam // Cmd is always nil, but keeping consistent signature with other handlers
 func (m model) handleBudgetsFetched(msg budgetsFetchedMsg) (model, tea.Cmd) {
+	if msg.seq != m.requestSeq || m.state != stateFetchingBudgets {
+		// Canceled with Esc
+		return m, nil
+	}
+	m.cancelRequest()
 	if msg.err != nil {
 		m.err = msg.err
 		m.state = stateError
@@ -292,28 +565,80 @@ func (m model) handleBudgetsFetched(msg budgetsFetchedMsg) (model, tea.Cmd) {
 	m.budgetList.SetShowStatusBar(false)
 	m.budgetList.SetFilteringEnabled(true)
 	m.budgetList.Styles.Title = titleStyle
+	m.budgetList.AdditionalShortHelpKeys = func() []key.Binding { return []key.Binding{toggleBudgetKey, exportAllKey} }
 	m.state = stateBudgetSelect
+
+	// Export everything straight away when requested on the command line
+	if m.exportAll {
+		m.exportAll = false
+		return m.startExportAll(m.budgets)
+	}
+
+	// A budget given on the command line skips the selection screen (only once,
+	// so going back to the list later still works)
+	if m.budgetQuery != "" {
+		query := m.budgetQuery
+		m.budgetQuery = ""
+		selected, err := findBudget(m.budgets, query)
+		if err != nil {
+			m.err = err
+			m.state = stateError
+			return m, nil
+		}
+		return m.startExport(selected)
+	}
 	return m, nil
 }
 
 // handleExportDone processes export done message.
 func (m model) handleExportDone(msg exportDoneMsg) (model, tea.Cmd) {
+	if m.state != stateExporting || msg.seq != m.exportSeq {
+		return m, nil // Canceled with Esc
+	}
+	m.cancelExport()
+	if m.quitting {
+		return m, tea.Quit
+	}
 	if msg.err != nil {
 		m.err = msg.err
 		m.state = stateError
 		return m, nil
 	}
 
-	m.exportPath = msg.path
+	m.exportPaths = msg.paths
 	m.summary = msg.summary
+	if m.selectedBudget.Name == "" {
+		m.selectedBudget.Name = msg.summary.Name
+	}
 
 	// Create budget structure table
-	m.budgetTable = createBudgetTable(msg.jsonData)
+	m.budgetTable = createBudgetTable(msg.structure)
 
 	m.state = stateDone
 	return m, tea.Quit
 }
 
+// handleExportAllDone processes the result of exporting several budgets.
+func (m model) handleExportAllDone(msg exportAllDoneMsg) (model, tea.Cmd) {
+	if m.state != stateExporting || msg.seq != m.exportSeq {
+		return m, nil // Canceled with Esc
+	}
+	m.cancelExport()
+	if m.quitting {
+		return m, tea.Quit
+	}
+	if msg.err != nil {
+		m.err = msg.err
+		m.state = stateError
+		return m, nil
+	}
+
+	m.exports = msg.exports
+	m.summaryPath = msg.summaryPath
+	m.state = stateDone
+	return m, tea.Quit
+}
+
 // updateInputs updates interactive components based on state.
 func (m model) updateInputs(msg tea.Msg) (model, tea.Cmd) {
 	var cmd tea.Cmd
@@ -341,7 +666,7 @@ func (m model) updateInputs(msg tea.Msg) (model, tea.Cmd) {
 		}
 	case stateBudgetSelect:
 		m.budgetList, cmd = m.budgetList.Update(msg)
-	case stateValidatingToken, stateFetchingBudgets, stateExporting, stateDone, stateError:
+	case stateValidatingToken, stateFetchingBudgets, stateRateLimitWarning, stateExporting, stateDone, stateError:
 		// No interactive input in these states
 	}
 	return m, cmd
@@ -361,6 +686,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleBudgetsFetched(msg)
 	case exportDoneMsg:
 		return m.handleExportDone(msg)
+	case exportAllDoneMsg:
+		return m.handleExportAllDone(msg)
+	case progressTickMsg:
+		// Keep redrawing until the export is done
+		if m.state == stateExporting {
+			return m, tickProgress()
+		}
+		return m, nil
 	}
 
 	return m.updateInputs(msg)
@@ -372,7 +705,8 @@ func (m model) View() string {
 	switch m.state {
 	case stateValidatingToken:
 		b.WriteString(titleStyle.Render("Validating Token...") + "\n\n")
-		b.WriteString("Please wait while we validate your YNAB API token.\n")
+		b.WriteString("Please wait while we validate your YNAB API token.\n\n")
+		b.WriteString(helpStyle.Render("Esc cancel • Ctrl+C quit"))
 
 	case stateToken:
 		b.WriteString(titleStyle.Render("YNAB Budget Exporter") + "\n\n")
@@ -400,40 +734,158 @@ func (m model) View() string {
 
 	case stateFetchingBudgets:
 		b.WriteString(titleStyle.Render("Fetching Budgets...") + "\n\n")
-		b.WriteString("Please wait while we retrieve your budgets from YNAB.\n")
+		b.WriteString("Please wait while we retrieve your budgets from YNAB.\n\n")
+		b.WriteString(helpStyle.Render("Esc cancel • Ctrl+C quit"))
 
 	case stateBudgetSelect:
 		b.WriteString(m.budgetList.View())
 
+	case stateRateLimitWarning:
+		now := time.Now()
+		needed := len(m.pendingBudgets)
+		b.WriteString(warningStyle.Render("⚠ API Rate Limit") + "\n\n")
+		b.WriteString("This export needs more API requests than are left this hour.\n\n")
+		b.WriteString(fmt.Sprintf("Requests needed: %d\n", needed))
+		b.WriteString(fmt.Sprintf("Requests left:   %d of %d\n", m.client.rateLimit.remaining(now), m.client.rateLimit.limit()))
+		b.WriteString(fmt.Sprintf("Available in:    %s\n\n", formatWait(m.client.rateLimit.waitFor(needed, now))))
+		b.WriteString(helpStyle.Render("w wait and export • c continue anyway • Esc go back"))
+
 	case stateExporting:
-		b.WriteString(titleStyle.Render("Exporting Budget...") + "\n\n")
-		b.WriteString(fmt.Sprintf("Downloading budget: %s\n", m.selectedBudget.Name))
-		b.WriteString("Please wait...\n")
+		if m.selectedBudget.ID == "" {
+			b.WriteString(titleStyle.Render("Exporting Budgets...") + "\n\n")
+			b.WriteString(fmt.Sprintf("Downloading %d budgets\n\n", m.batchSize))
+		} else {
+			b.WriteString(titleStyle.Render("Exporting Budget...") + "\n\n")
+			b.WriteString(fmt.Sprintf("Downloading budget: %s\n\n", cmp.Or(m.selectedBudget.Name, m.selectedBudget.ID)))
+			// The size is only known for a single budget whose response has a Content-Length
+			if fraction, ok := m.download.fraction(); ok {
+				b.WriteString(m.progressBar.ViewAs(fraction) + "\n")
+			}
+		}
+		b.WriteString(m.download.String() + "\n\n")
+		b.WriteString(helpStyle.Render("Esc cancel • Ctrl+C cancel and quit"))
 
 	case stateDone:
-		b.WriteString(successStyle.Render("✓ Export Complete!") + "\n\n")
-		b.WriteString(fmt.Sprintf("Budget: %s\n", m.selectedBudget.Name))
-		b.WriteString(fmt.Sprintf("Saved to: %s\n", m.exportPath))
-		b.WriteString(fmt.Sprintf("File Size: %s\n\n", humanizeFileSize(m.summary.FileSize)))
-
-		// Display budget structure table
-		b.WriteString(titleStyle.Render("Budget Structure (data.budget):") + "\n")
-		b.WriteString(m.budgetTable + "\n\n")
-
-		b.WriteString("You can now import this file into Actual Budget:\n")
-		b.WriteString("  1. Open Actual Budget\n")
-		b.WriteString("  2. If a budget is already open, select the dropdown menu and 'Close File'\n")
-		b.WriteString("  3. Select 'Import file'\n")
-		b.WriteString("  4. Choose 'nYNAB'\n")
-		b.WriteString("  5. Select the exported JSON file\n")
-		b.WriteString("  6. Once imported, review your budget and follow cleanup steps at\n")
-		b.WriteString("     https://actualbudget.org/docs/migration/nynab#cleanup\n")
+		if m.exports != nil {
+			b.WriteString(m.exportsView())
+		} else {
+			b.WriteString(successStyle.Render("✓ Export Complete!") + "\n\n")
+			b.WriteString(fmt.Sprintf("Budget: %s\n", m.selectedBudget.Name))
+			if m.exportOpts.toStdout() {
+				b.WriteString("Written to: standard output\n")
+			} else {
+				b.WriteString(fmt.Sprintf("Saved to: %s\n", strings.Join(m.exportPaths, "\n          ")))
+			}
+			b.WriteString(fmt.Sprintf("File Size: %s\n", formatExportSize(m.summary)))
+			if m.exportOpts.sinceLast {
+				b.WriteString(fmt.Sprintf("Export Type: %s\n", formatExportType(m.summary)))
+			}
+			b.WriteString(fmt.Sprintf("Encrypted: %s\n\n", formatEncryption(m.summary)))
+
+			// Display budget structure table
+			b.WriteString(titleStyle.Render("Budget Structure (data.budget):") + "\n")
+			b.WriteString(m.budgetTable + "\n\n")
+		}
+
+		if m.exportOpts.encrypt.enabled() {
+			b.WriteString("Decrypt the export first with: ynab-export decrypt <file>\n\n")
+		}
+		// Only JSON exports can be imported into Actual Budget
+		if m.exportOpts.format == formatJSON {
+			b.WriteString("You can now import this file into Actual Budget:\n")
+			b.WriteString("  1. Open Actual Budget\n")
+			b.WriteString("  2. If a budget is already open, select the dropdown menu and 'Close File'\n")
+			b.WriteString("  3. Select 'Import file'\n")
+			b.WriteString("  4. Choose 'nYNAB'\n")
+			b.WriteString("  5. Select the exported JSON file\n")
+			b.WriteString("  6. Once imported, review your budget and follow cleanup steps at\n")
+			b.WriteString("     https://actualbudget.org/docs/migration/nynab#cleanup\n")
+		}
 
 	case stateError:
 		b.WriteString(errorStyle.Render("✗ Error") + "\n\n")
 		b.WriteString(fmt.Sprintf("An error occurred: %v\n\n", m.err))
+		if m.api.logger != nil {
+			b.WriteString(fmt.Sprintf("See the debug log for the requests made: %s\n\n", m.api.logFile))
+		}
 		b.WriteString(helpStyle.Render("Press Enter or q to quit"))
 	}
 
 	return b.String()
 }
+
+// exportsView renders the per-budget results of a batch export.
+func (m model) exportsView() string {
+	var b strings.Builder
+
+	failed := 0
+	for _, e := range m.exports {
+		if e.err != nil {
+			failed++
+		}
+	}
+	if failed == 0 {
+		b.WriteString(successStyle.Render(fmt.Sprintf("✓ Exported %d Budgets!", len(m.exports))) + "\n\n")
+	} else {
+		b.WriteString(warningStyle.Render(fmt.Sprintf("⚠ Exported %d of %d Budgets", len(m.exports)-failed, len(m.exports))) + "\n\n")
+	}
+
+	b.WriteString(createExportsTable(m.exports) + "\n")
+	if m.exportOpts.encrypt.enabled() {
+		b.WriteString(fmt.Sprintf("Encrypted: yes, %s\n", m.exportOpts.encrypt))
+	}
+	b.WriteString("\n")
+
+	for _, e := range m.exports {
+		if e.err != nil {
+			b.WriteString(errorStyle.Render("✗ ") + fmt.Sprintf("%s: %v\n", e.budget.Name, e.err))
+			continue
+		}
+		b.WriteString(validStyle.Render("✓ ") + fmt.Sprintf("%s → %s\n", e.budget.Name, strings.Join(e.result.paths, ", ")))
+	}
+	b.WriteString(fmt.Sprintf("\nRun summary: %s\n\n", m.summaryPath))
+
+	return b.String()
+}
+
+// createExportsTable creates a per-budget summary table for a batch export.
+func createExportsTable(exports []budgetExport) string {
+	headers := []string{"Budget", "Status", "Currency", "Months", "Accounts", "Transactions", "Categories", "Payees", "Size"}
+	rows := make([][]string, 0, len(exports))
+	for _, e := range exports {
+		if e.err != nil {
+			rows = append(rows, []string{e.budget.Name, "✗ failed", "", "", "", "", "", "", ""})
+			continue
+		}
+		s := e.result.summary
+		rows = append(rows, []string{
+			s.Name,
+			"✓ saved",
+			s.Currency,
+			fmt.Sprintf("%s – %s", formatMonthYear(s.FirstMonth), formatMonthYear(s.LastMonth)),
+			fmt.Sprintf("%d (+%d closed)", s.AccountCount, s.ClosedAccountCount),
+			strconv.Itoa(s.TransactionCount),
+			fmt.Sprintf("%d (+%d hidden)", s.CategoryCount, s.HiddenCategoryCount),
+			strconv.Itoa(s.PayeeCount),
+			formatExportSize(s),
+		})
+	}
+
+	t := table.New().
+		Border(lipgloss.RoundedBorder()).
+		Headers(headers...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return fieldStyle.Bold(true)
+			case col == 1 && exports[row].err != nil:
+				return errorStyle
+			case col == 1:
+				return validStyle
+			}
+			return lipgloss.NewStyle()
+		}).
+		Rows(rows...)
+
+	return t.Render()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimitWarningIsPerExport(t *testing.T) {
+	isolateCache(t)
+	tracker := &rateTracker{log: requestLog{Limit: 1, Requests: []time.Time{time.Now()}}}
+	m := model{client: &client{rateLimit: tracker}, exportOpts: exportOptions{format: formatJSON}}
+	budgets := []budget{{ID: "b1", Name: "Budget"}}
+
+	m, warned := m.warnRateLimit(budgets, false)
+	if !warned || m.state != stateRateLimitWarning {
+		t.Fatalf("first export was not warned about the rate limit")
+	}
+
+	m, _ = m.resumeExport(false)
+	if m.state != stateExporting {
+		t.Fatalf("confirmed export did not start, state = %v", m.state)
+	}
+	m.cancelExport()
+
+	if _, warned := m.warnRateLimit(budgets, false); !warned {
+		t.Error("a later export was not warned about the rate limit")
+	}
+}
+
+func TestEscCancelsTokenValidation(t *testing.T) {
+	isolateCache(t)
+	m := initialModel("token", TokenSourceFlag, options{})
+	ctx, seq := m.requestCtx, m.requestSeq
+
+	m, _ = m.handleKeyPress("esc")
+	if m.state != stateToken {
+		t.Fatalf("state after Esc = %v, want token entry", m.state)
+	}
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("validation context error = %v, want %v", ctx.Err(), context.Canceled)
+	}
+
+	// The canceled validation's result arrives after going back
+	m, _ = m.handleTokenValidated(tokenValidatedMsg{err: ctx.Err(), seq: seq})
+	if m.state != stateToken || m.tokenValidationErr != "" {
+		t.Errorf("canceled validation was not ignored, state = %v, error = %q", m.state, m.tokenValidationErr)
+	}
+}
@@ -1,41 +1,173 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"encoding/json/v2"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
-	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+	"github.com/sahilm/fuzzy"
 )
 
-// ynabAPIBase is the base URL for the YNAB API.
-// It can be overridden for demo mode or testing.
-var ynabAPIBase = "https://api.ynab.com/v1"
+// errWriteExport marks failures to write the export file to disk.
+var errWriteExport = errors.New("failed to write export")
+
+// apiError is returned when the YNAB API responds with a non-200 status.
+// ID, Name and Detail come from the YNAB error response, when the body is one.
+type apiError struct {
+	Status     string
+	Body       string
+	ID         string // e.g. "404.2"
+	Name       string // e.g. "resource_not_found"
+	Detail     string
+	StatusCode int
+}
+
+func (e *apiError) Error() string {
+	switch {
+	case e.Name != "":
+		return fmt.Sprintf("API error: %s - %s: %s", e.Status, e.Name, e.Detail)
+	case e.Body != "":
+		return fmt.Sprintf("API error: %s - %s", e.Status, e.Body)
+	}
+	return "API error: " + e.Status
+}
+
+// errorResponse is the body of a YNAB API error response.
+type errorResponse struct {
+	Error struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Detail string `json:"detail"`
+	} `json:"error"`
+}
+
+// newAPIError builds an apiError from a non-200 response, including its body when readable.
+func newAPIError(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("API error: %s (failed to read body: %w)", resp.Status, err)
+	}
+	apiErr := &apiError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(body))}
+
+	var errResp errorResponse
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error.Name != "" {
+		apiErr.ID = errResp.Error.ID
+		apiErr.Name = errResp.Error.Name
+		apiErr.Detail = errResp.Error.Detail
+	}
+	return apiErr
+}
 
 type budget struct {
 	ID             string `json:"id"`
 	Name           string `json:"name"`
 	LastModifiedOn string `json:"last_modified_on"`
+	selected       bool   // Marked for export in the TUI budget list
 }
 
 func (b budget) Title() string {
+	title := b.Name
 	if b.LastModifiedOn != "" {
 		t, err := time.Parse(time.RFC3339, b.LastModifiedOn)
 		if err == nil {
-			return fmt.Sprintf("%s (Last Modified: %s)", b.Name, t.Format("2006-01-02"))
+			title = fmt.Sprintf("%s (Last Modified: %s)", b.Name, t.Format("2006-01-02"))
 		}
 	}
-	return b.Name
+	if b.selected {
+		return "✓ " + title
+	}
+	return title
 }
 func (b budget) Description() string { return b.ID }
 func (b budget) FilterValue() string { return b.Name }
 
+// Special budget IDs accepted by the YNAB API in place of a UUID.
+const (
+	budgetIDLastUsed = "last-used"
+	budgetIDDefault  = "default"
+)
+
+// findBudget resolves query to a budget. The query may be a budget UUID, the special
+// IDs "last-used" or "default", an exact (case-insensitive) name, or a partial or fuzzy name.
+// Names matching more than one budget return an error listing the candidates.
+func findBudget(budgets []budget, query string) (budget, error) {
+	query = strings.TrimSpace(query)
+
+	// Special IDs are resolved by the API, so the name is unknown until the budget is downloaded
+	switch strings.ToLower(query) {
+	case budgetIDLastUsed, budgetIDDefault:
+		return budget{ID: strings.ToLower(query)}, nil
+	}
+
+	if _, err := uuid.Parse(query); err == nil {
+		for _, b := range budgets {
+			if strings.EqualFold(b.ID, query) {
+				return b, nil
+			}
+		}
+		return budget{}, fmt.Errorf("%w: no budget with ID %s", errBudgetNotFound, query)
+	}
+
+	// Try progressively looser name matches, stopping at the first that finds anything
+	matchers := []func() []budget{
+		func() []budget {
+			return filterBudgets(budgets, func(b budget) bool { return strings.EqualFold(b.Name, query) })
+		},
+		func() []budget {
+			lowerQuery := strings.ToLower(query)
+			return filterBudgets(budgets, func(b budget) bool { return strings.Contains(strings.ToLower(b.Name), lowerQuery) })
+		},
+		func() []budget {
+			names := make([]string, len(budgets))
+			for i, b := range budgets {
+				names[i] = b.Name
+			}
+			var matches []budget
+			for _, match := range fuzzy.Find(query, names) {
+				matches = append(matches, budgets[match.Index])
+			}
+			return matches
+		},
+	}
+	for _, match := range matchers {
+		switch matches := match(); len(matches) {
+		case 0:
+			continue
+		case 1:
+			return matches[0], nil
+		default:
+			candidates := make([]string, len(matches))
+			for i, b := range matches {
+				candidates[i] = fmt.Sprintf("%q (%s)", b.Name, b.ID)
+			}
+			return budget{}, fmt.Errorf("%w: %q matches %s", errAmbiguousName, query, strings.Join(candidates, ", "))
+		}
+	}
+
+	return budget{}, fmt.Errorf("%w: %q", errBudgetNotFound, query)
+}
+
+// filterBudgets returns the budgets for which keep returns true.
+func filterBudgets(budgets []budget, keep func(budget) bool) []budget {
+	var matches []budget
+	for _, b := range budgets {
+		if keep(b) {
+			matches = append(matches, b)
+		}
+	}
+	return matches
+}
+
 type budgetsResponse struct {
 	Data struct {
 		Budgets []budget `json:"budgets"`
@@ -44,41 +176,114 @@ type budgetsResponse struct {
 
 type budgetDetailResponse struct {
 	Data struct {
-		Budget budgetDetail `json:"budget"`
+		Budget          budgetDetail `json:"budget"`
+		ServerKnowledge int64        `json:"server_knowledge"`
 	} `json:"data"`
 }
 
 type budgetDetail struct {
-	Name           string         `json:"name"`
-	FirstMonth     string         `json:"first_month"`
-	LastMonth      string         `json:"last_month"`
-	CurrencyFormat currencyFormat `json:"currency_format"`
-	Accounts       []account      `json:"accounts"`
-	Payees         []payee        `json:"payees"`
-	Categories     []category     `json:"categories"`
-	Transactions   []transaction  `json:"transactions"`
+	ID              string           `json:"id"`
+	Name            string           `json:"name"`
+	FirstMonth      string           `json:"first_month"`
+	LastMonth       string           `json:"last_month"`
+	CurrencyFormat  currencyFormat   `json:"currency_format"`
+	Accounts        []account        `json:"accounts"`
+	Payees          []payee          `json:"payees"`
+	CategoryGroups  []categoryGroup  `json:"category_groups"`
+	Categories      []category       `json:"categories"`
+	Transactions    []transaction    `json:"transactions"`
+	Subtransactions []subtransaction `json:"subtransactions"`
 }
 
 type currencyFormat struct {
-	ISOCode        string `json:"iso_code"`
-	CurrencySymbol string `json:"currency_symbol"`
+	ISOCode          string `json:"iso_code"`
+	CurrencySymbol   string `json:"currency_symbol"`
+	DecimalSeparator string `json:"decimal_separator"`
+	GroupSeparator   string `json:"group_separator"`
+	DecimalDigits    int    `json:"decimal_digits"`
+	SymbolFirst      bool   `json:"symbol_first"`
+	DisplaySymbol    bool   `json:"display_symbol"`
 }
 
+// account is an account in a budget. Balances are in milliunits.
 type account struct {
-	Closed bool `json:"closed"`
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Type           string `json:"type"` // e.g. "checking", "creditCard" or "mortgage"
+	Balance        int64  `json:"balance"`
+	ClearedBalance int64  `json:"cleared_balance"`
+	OnBudget       bool   `json:"on_budget"`
+	Closed         bool   `json:"closed"`
+	Deleted        bool   `json:"deleted"`
+}
+
+// YNAB account types that formats other than JSON treat specially.
+const (
+	accountTypeChecking     = "checking"
+	accountTypeSavings      = "savings"
+	accountTypeCash         = "cash"
+	accountTypeCreditCard   = "creditCard"
+	accountTypeLineOfCredit = "lineOfCredit"
+	accountTypeOtherAsset   = "otherAsset"
+)
+
+// liability reports whether the account holds debt, such as a credit card or loan.
+func (a account) liability() bool {
+	switch a.Type {
+	case accountTypeChecking, accountTypeSavings, accountTypeCash, accountTypeOtherAsset:
+		return false
+	}
+	return true
 }
 
 type payee struct {
-	ID string `json:"id"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type categoryGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 type category struct {
-	Deleted bool `json:"deleted"`
-	Hidden  bool `json:"hidden"`
+	ID              string `json:"id"`
+	CategoryGroupID string `json:"category_group_id"`
+	Name            string `json:"name"`
+	Deleted         bool   `json:"deleted"`
+	Hidden          bool   `json:"hidden"`
 }
 
+// transaction is a transaction in a budget. Amounts are in milliunits, and the
+// IDs of a transaction's account, payee and category refer to the budget's lists.
 type transaction struct {
-	ID string `json:"id"`
+	ID                    string `json:"id"`
+	Date                  string `json:"date"`
+	AccountID             string `json:"account_id"`
+	PayeeID               string `json:"payee_id"`
+	CategoryID            string `json:"category_id"`
+	TransferAccountID     string `json:"transfer_account_id"`
+	TransferTransactionID string `json:"transfer_transaction_id"` // The other side of a transfer
+	Memo                  string `json:"memo"`
+	Cleared               string `json:"cleared"`
+	FlagColor             string `json:"flag_color"`
+	FlagName              string `json:"flag_name"`
+	Amount                int64  `json:"amount"`
+	Approved              bool   `json:"approved"`
+	Deleted               bool   `json:"deleted"`
+}
+
+// subtransaction is one part of a split transaction. Its payee and memo are
+// empty when it uses the parent transaction's.
+type subtransaction struct {
+	ID                string `json:"id"`
+	TransactionID     string `json:"transaction_id"`
+	PayeeID           string `json:"payee_id"`
+	CategoryID        string `json:"category_id"`
+	TransferAccountID string `json:"transfer_account_id"`
+	Memo              string `json:"memo"`
+	Amount            int64  `json:"amount"`
+	Deleted           bool   `json:"deleted"`
 }
 
 type budgetSummary struct {
@@ -86,7 +291,12 @@ type budgetSummary struct {
 	Currency             string
 	FirstMonth           string
 	LastMonth            string
-	FileSize             int64
+	Compression          string
+	Encryption           string
+	ServerKnowledge      int64
+	DeltaSince           int64 // Server knowledge the delta starts from; 0 for a full export
+	FileSize             int64 // Size of the files written, before compression
+	CompressedSize       int64
 	AccountCount         int
 	ClosedAccountCount   int
 	TransactionCount     int
@@ -96,71 +306,35 @@ type budgetSummary struct {
 	PayeeCount           int
 }
 
-// validateToken checks if a token is valid by calling the /user endpoint.
-func validateToken(token string) error {
-	client := &http.Client{Timeout: 10 * time.Second}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ynabAPIBase+"/user", http.NoBody)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	resp, err := client.Do(req)
+// validateToken checks if the client's token is valid by calling the /user endpoint.
+func (c *client) validateToken(ctx context.Context) error {
+	resp, err := c.get(ctx, "/user", nil)
 	if err != nil {
-		return fmt.Errorf("failed to validate token: %w", err)
-	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil && err == nil {
-			err = closeErr
+		var apiErr *apiError
+		if errors.As(err, &apiErr) &&
+			(apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden) {
+			return fmt.Errorf("invalid token: %w", err)
 		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("invalid token: API returned %s", resp.Status)
+		return fmt.Errorf("failed to validate token: %w", err)
 	}
+	_ = resp.Body.Close() //nolint:errcheck // Only the status matters
 
 	return nil
 }
 
-func fetchBudgets(token string) tea.Msg {
-	client := &http.Client{Timeout: 30 * time.Second}
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ynabAPIBase+"/budgets", http.NoBody)
-	if err != nil {
-		return budgetsFetchedMsg{err: err}
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	resp, err := client.Do(req)
-	if err != nil {
-		return budgetsFetchedMsg{err: err}
-	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil && err == nil {
-			err = closeErr
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		body, readErr := io.ReadAll(resp.Body)
-		if readErr != nil {
-			return budgetsFetchedMsg{err: fmt.Errorf("API error: %s (failed to read body: %w)", resp.Status, readErr)}
-		}
-		return budgetsFetchedMsg{err: fmt.Errorf("API error: %s - %s", resp.Status, string(body))}
-	}
-
-	body, err := io.ReadAll(resp.Body)
+func fetchBudgets(ctx context.Context, c *client) budgetsFetchedMsg {
+	budgets, err := c.listBudgets(ctx)
 	if err != nil {
 		return budgetsFetchedMsg{err: err}
 	}
+	return budgetsFetchedMsg{budgets: budgets}
+}
 
+// listBudgets retrieves all budgets, sorted by last modified date (most recent first).
+func (c *client) listBudgets(ctx context.Context) ([]budget, error) {
 	var budgetsResp budgetsResponse
-	if err := json.Unmarshal(body, &budgetsResp); err != nil {
-		return budgetsFetchedMsg{err: err}
+	if err := c.getJSON(ctx, "/budgets", nil, &budgetsResp); err != nil {
+		return nil, fmt.Errorf("failed to fetch budgets: %w", err)
 	}
 
 	// Sort budgets by last modified date (most recent first)
@@ -175,11 +349,11 @@ func fetchBudgets(token string) tea.Msg {
 		}
 	}
 
-	return budgetsFetchedMsg{budgets: budgets}
+	return budgets, nil
 }
 
 // createBudgetSummary extracts summary statistics from a budget.
-func createBudgetSummary(budget budgetDetail, fileSize int64) budgetSummary {
+func createBudgetSummary(budget budgetDetail) budgetSummary {
 	// Count categories (non-deleted, non-hidden, hidden, deleted)
 	categoryCount := 0
 	hiddenCategoryCount := 0
@@ -217,7 +391,6 @@ func createBudgetSummary(budget budgetDetail, fileSize int64) budgetSummary {
 		Currency:             currency,
 		FirstMonth:           budget.FirstMonth,
 		LastMonth:            budget.LastMonth,
-		FileSize:             fileSize,
 		AccountCount:         accountCount,
 		ClosedAccountCount:   closedAccountCount,
 		TransactionCount:     len(budget.Transactions),
@@ -228,75 +401,148 @@ func createBudgetSummary(budget budgetDetail, fileSize int64) budgetSummary {
 	}
 }
 
-func exportBudget(token, budgetID, budgetName string) tea.Msg {
-	client := &http.Client{Timeout: 30 * time.Second}
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	url := fmt.Sprintf("%s/budgets/%s", ynabAPIBase, budgetID)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
-	if err != nil {
-		return exportDoneMsg{err: err}
-	}
+// exportResult describes a budget export that was written to disk.
+type exportResult struct {
+	paths     []string // Several for formats written per account
+	structure OrderedObject[string]
+	summary   budgetSummary
+}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-	resp, err := client.Do(req)
+func exportBudget(ctx context.Context, c *client, budgetID, budgetName string, opts exportOptions,
+	progress *transferProgress,
+) exportDoneMsg {
+	result, err := c.downloadBudget(ctx, budgetID, budgetName, opts, progress)
 	if err != nil {
 		return exportDoneMsg{err: err}
 	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil && err == nil {
-			err = closeErr
-		}
-	}()
+	return exportDoneMsg{paths: result.paths, summary: result.summary, structure: result.structure}
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, readErr := io.ReadAll(resp.Body)
-		if readErr != nil {
-			return exportDoneMsg{err: fmt.Errorf("API error: %s (failed to read body: %w)", resp.Status, readErr)}
+// downloadBudget fetches the budget and writes it to the output directory.
+// With opts.sinceLast, only the changes since the budget's last export are fetched
+// when an earlier export is recorded.
+func (c *client) downloadBudget(ctx context.Context, budgetID, budgetName string, opts exportOptions,
+	progress *transferProgress,
+) (exportResult, error) {
+	var since int64
+	delta := false
+	if opts.sinceLast {
+		switch budgetID {
+		case budgetIDLastUsed, budgetIDDefault:
+			return exportResult{}, fmt.Errorf("--since-last needs a budget ID or name, not %q", budgetID)
+		}
+		var err error
+		since, delta, err = lastKnowledge(budgetID)
+		if err != nil {
+			return exportResult{}, err
 		}
-		return exportDoneMsg{err: fmt.Errorf("API error: %s - %s", resp.Status, string(body))}
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	query := url.Values{}
+	if delta {
+		query.Set("last_knowledge_of_server", strconv.FormatInt(since, 10))
+	}
+	resp, err := c.get(ctx, "/budgets/"+url.PathEscape(budgetID), query)
 	if err != nil {
-		return exportDoneMsg{err: err}
+		return exportResult{}, fmt.Errorf("failed to download budget: %w", err)
 	}
+	defer resp.Body.Close() //nolint:errcheck // Body fully read below
 
-	// Parse the budget data to extract summary information
-	var budgetResp budgetDetailResponse
-	if unmarshalErr := json.Unmarshal(body, &budgetResp); unmarshalErr != nil {
-		return exportDoneMsg{err: unmarshalErr}
+	// Stream the budget to a temporary file next to the export, since the name of
+	// the export depends on the budget's contents
+	dir, err := opts.downloadDir()
+	if err != nil {
+		return exportResult{}, err
 	}
+	raw, err := createAtomic(filepath.Join(dir, "ynab-download.json"))
+	if err != nil {
+		return exportResult{}, err
+	}
+	defer raw.Abort() // No-op once the download is moved into place
 
-	budget := budgetResp.Data.Budget
-	summary := createBudgetSummary(budget, int64(len(body)))
-
-	// Get user's home directory
-	homeDir, err := os.UserHomeDir()
+	progress.expect(resp.ContentLength)
+	size, err := io.Copy(raw, progress.reader(resp.Body))
 	if err != nil {
-		return exportDoneMsg{err: err}
+		return exportResult{}, fmt.Errorf("failed to read budget: %w", err)
 	}
 
-	// Create Downloads directory path (cross-platform)
-	downloadsDir := filepath.Join(homeDir, "Downloads")
+	var (
+		budget          budgetDetail
+		serverKnowledge int64
+		structure       OrderedObject[string]
+		summary         budgetSummary
+		download        = raw
+	)
+	if opts.format == formatNDJSON {
+		// The summary comes from the same pass that converts the budget, since
+		// parsing a large budget whole would need it all in memory
+		download, err = createAtomic(filepath.Join(dir, "ynab-download.ndjson"))
+		if err != nil {
+			return exportResult{}, err
+		}
+		defer download.Abort() // No-op once the export is moved into place
 
-	// Ensure Downloads directory exists
-	if err := os.MkdirAll(downloadsDir, 0o750); err != nil {
-		return exportDoneMsg{err: err}
+		info, err := writeNDJSON(download, raw, size, budgetID)
+		if err != nil {
+			return exportResult{}, err
+		}
+		budget, serverKnowledge, structure = info.detail, info.serverKnowledge, info.structure
+		summary = createBudgetSummary(budget)
+		summary.TransactionCount = info.transactionCount
+	} else {
+		// Parse the budget data to extract summary information
+		var budgetResp budgetDetailResponse
+		if _, err := raw.Seek(0, io.SeekStart); err != nil {
+			return exportResult{}, fmt.Errorf("failed to read budget: %w", err)
+		}
+		if err := json.UnmarshalRead(raw, &budgetResp); err != nil {
+			return exportResult{}, fmt.Errorf("failed to parse budget: %w", err)
+		}
+		if _, err := raw.Seek(0, io.SeekStart); err != nil {
+			return exportResult{}, fmt.Errorf("failed to read budget: %w", err)
+		}
+		structure, err = describeBudget(raw)
+		if err != nil {
+			return exportResult{}, fmt.Errorf("failed to parse budget: %w", err)
+		}
+
+		budget = budgetResp.Data.Budget
+		serverKnowledge = budgetResp.Data.ServerKnowledge
+		summary = createBudgetSummary(budget)
+	}
+	summary.ServerKnowledge = serverKnowledge
+	if delta {
+		summary.DeltaSince = since
 	}
 
-	// Create filename with timestamp and budget name
-	timestamp := time.Now().Format("20060102-150405")
-	// Sanitize budget name: lowercase and replace spaces with dashes
-	sanitizedName := strings.ToLower(budgetName)
-	sanitizedName = strings.ReplaceAll(sanitizedName, " ", "-")
-	filename := fmt.Sprintf("ynab-export-%s-%s.json", sanitizedName, timestamp)
-	filePath := filepath.Join(downloadsDir, filename)
+	// Budgets requested by a special ID only learn their name from the download
+	if budgetName == "" {
+		budgetName = budget.Name
+	}
 
-	// Write the JSON to file
-	if err := os.WriteFile(filePath, body, 0o600); err != nil {
-		return exportDoneMsg{err: err}
+	// The export goes to stdout for piping, so there is no file to name
+	filePath := stdoutTarget
+	if !opts.toStdout() {
+		filePath, err = opts.outputPath(budgetID, budgetName, budget, serverKnowledge, delta)
+		if err != nil {
+			return exportResult{}, err
+		}
+	}
+
+	// Write the export to file
+	paths, written, err := opts.writeDownload(ctx, download, filePath, budget)
+	if err != nil {
+		return exportResult{}, err
+	}
+	summary.FileSize = written.raw
+	if opts.compress != compressionNone {
+		summary.Compression = string(opts.compress)
+		summary.CompressedSize = written.compressed
 	}
+	summary.Encryption = opts.encrypt.String()
+
+	// A failure here only means the next --since-last export repeats some changes
+	_ = saveKnowledge(cmp.Or(budget.ID, budgetID), serverKnowledge) //nolint:errcheck // Export already written
 
-	return exportDoneMsg{path: filePath, summary: summary, jsonData: body}
+	return exportResult{paths: paths, summary: summary, structure: structure}, nil
 }
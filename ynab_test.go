@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFindBudget(t *testing.T) {
+	budgets := []budget{
+		{ID: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a01", Name: "Personal Budget"},
+		{ID: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a02", Name: "Business"},
+		{ID: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a03", Name: "Business (Archived)"},
+		{ID: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a04", Name: "Vacation Home"},
+		{ID: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a05", Name: "Vacation Fund"},
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantID  string
+		wantErr error
+	}{
+		{name: "last-used", query: "last-used", wantID: "last-used"},
+		{name: "default ignores case", query: " Default ", wantID: "default"},
+		{name: "ID", query: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a04", wantID: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a04"},
+		{name: "ID ignores case", query: "0B0F6A9E-5A0C-4C4E-9D4B-1F1D1E5C0A04", wantID: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a04"},
+		{name: "unknown ID", query: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a99", wantErr: errBudgetNotFound},
+		{name: "exact name wins over substring", query: "business", wantID: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a02"},
+		{name: "unique substring", query: "personal", wantID: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a01"},
+		{name: "ambiguous substring", query: "vacation", wantErr: errAmbiguousName},
+		{name: "fuzzy", query: "prsnl", wantID: "0b0f6a9e-5a0c-4c4e-9d4b-1f1d1e5c0a01"},
+		{name: "ambiguous fuzzy", query: "vctn", wantErr: errAmbiguousName},
+		{name: "no match", query: "groceries", wantErr: errBudgetNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findBudget(budgets, tt.query)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("findBudget(%q) error = %v, want %v", tt.query, err, tt.wantErr)
+			}
+			if got.ID != tt.wantID {
+				t.Errorf("findBudget(%q) = %q, want %q", tt.query, got.ID, tt.wantID)
+			}
+		})
+	}
+}